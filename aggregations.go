@@ -2,6 +2,7 @@ package godruid
 
 import (
 	"encoding/json"
+	"math"
 )
 
 type Aggregation struct {
@@ -15,8 +16,35 @@ type Aggregation struct {
 	ByRow       *ByRow       `json:"byRow,omitempty"`
 	AggFilter   *Filter      `json:"filter,omitempty"`
 	Aggregator  *Aggregation `json:"aggregator,omitempty"`
+	// The first, last and any aggregators.
+	MaxStringBytes int    `json:"maxStringBytes,omitempty"`
+	TimeColumn     string `json:"timeColumn,omitempty"`
+	// The sketch aggregators.
+	Size               int                 `json:"size,omitempty"`
+	ShouldFinalize     *ShouldFinalize     `json:"shouldFinalize,omitempty"`
+	IsInputThetaSketch *IsInputThetaSketch `json:"isInputThetaSketch,omitempty"`
+	ErrorBoundsStdDev  int                 `json:"errorBoundsStdDev,omitempty"`
+	LgK                int                 `json:"lgK,omitempty"`
+	TgtHllType         HLLType             `json:"tgtHllType,omitempty"`
+	Round              *Round              `json:"round,omitempty"`
+	K                  int                 `json:"k,omitempty"`
+	// The histogram aggregators.
+	Resolution          int      `json:"resolution,omitempty"`
+	NumBuckets          int      `json:"numBuckets,omitempty"`
+	LowerLimit          *float64 `json:"lowerLimit,omitempty"`
+	UpperLimit          *float64 `json:"upperLimit,omitempty"`
+	OutlierHandlingMode string   `json:"outlierHandlingMode,omitempty"`
 }
 
+// HLLType is the type of the HLL sketches, the number of bits per bucket.
+type HLLType string
+
+const (
+	HLL4 HLLType = "HLL_4"
+	HLL6 HLLType = "HLL_6"
+	HLL8 HLLType = "HLL_8"
+)
+
 // ---------------------------------
 // Options
 // ---------------------------------
@@ -29,6 +57,82 @@ type ByRow bool
 
 func (b ByRow) apply(c *Aggregation) { c.ByRow = &b }
 
+// MaxStringBytes is the maximum size of the values kept by the string first, last and any aggregators.
+type MaxStringBytes int
+
+func (i MaxStringBytes) apply(c *Aggregation) { c.MaxStringBytes = int(i) }
+
+// Size is the maximum number of entries of a theta sketch, a power of 2.
+type Size int
+
+func (i Size) apply(c *Aggregation) { c.Size = int(i) }
+
+type ShouldFinalize bool
+
+func (b ShouldFinalize) apply(c *Aggregation) { c.ShouldFinalize = &b }
+
+type IsInputThetaSketch bool
+
+func (b IsInputThetaSketch) apply(c *Aggregation) { c.IsInputThetaSketch = &b }
+
+// ErrorBoundsStdDev makes a finalized theta sketch include the error bounds at this many standard deviations.
+type ErrorBoundsStdDev int
+
+func (i ErrorBoundsStdDev) apply(c *Aggregation) { c.ErrorBoundsStdDev = int(i) }
+
+// LgK is the log2 of the number of buckets of a HLL sketch.
+type LgK int
+
+func (i LgK) apply(c *Aggregation) { c.LgK = int(i) }
+
+func (t HLLType) apply(c *Aggregation) { c.TgtHllType = t }
+
+// Round rounds the finalized HLL estimates to the nearest integer.
+type Round bool
+
+func (b Round) apply(c *Aggregation) { c.Round = &b }
+
+// SketchK is the accuracy parameter of the quantiles sketches, a power of 2.
+type SketchK int
+
+func (i SketchK) apply(c *Aggregation) { c.K = int(i) }
+
+// Resolution is the number of centroids of an approximate histogram.
+type Resolution int
+
+func (i Resolution) apply(c *Aggregation) { c.Resolution = int(i) }
+
+// NumBuckets is the number of buckets of the finalized approximate histogram.
+type NumBuckets int
+
+func (i NumBuckets) apply(c *Aggregation) { c.NumBuckets = int(i) }
+
+// LowerLimit is the value under which the approximate histogram ignores the values.
+type LowerLimit float64
+
+func (f LowerLimit) apply(c *Aggregation) { v := float64(f); c.LowerLimit = &v }
+
+// UpperLimit is the value above which the approximate histogram ignores the values.
+type UpperLimit float64
+
+func (f UpperLimit) apply(c *Aggregation) { v := float64(f); c.UpperLimit = &v }
+
+// OutlierHandlingMode is what a fixed buckets histogram does with the values out of its limits.
+type OutlierHandlingMode string
+
+const (
+	OutlierIgnore   OutlierHandlingMode = "ignore"
+	OutlierOverflow OutlierHandlingMode = "overflow"
+	OutlierClip     OutlierHandlingMode = "clip"
+)
+
+func (m OutlierHandlingMode) apply(c *Aggregation) { c.OutlierHandlingMode = string(m) }
+
+// TimeColumn is the column ordering the rows of the first and last aggregators, "__time" by default.
+type TimeColumn string
+
+func (s TimeColumn) apply(c *Aggregation) { c.TimeColumn = string(s) }
+
 // ---------------------------------
 // Constructors
 // ---------------------------------
@@ -94,6 +198,66 @@ func AggDoubleMax(name, fieldName string) Aggregation {
 	}
 }
 
+func AggFloatSum(name, fieldName string) Aggregation {
+	return Aggregation{
+		Type:      "floatSum",
+		Name:      name,
+		FieldName: fieldName,
+	}
+}
+
+func AggFloatMin(name, fieldName string) Aggregation {
+	return Aggregation{
+		Type:      "floatMin",
+		Name:      name,
+		FieldName: fieldName,
+	}
+}
+
+func AggFloatMax(name, fieldName string) Aggregation {
+	return Aggregation{
+		Type:      "floatMax",
+		Name:      name,
+		FieldName: fieldName,
+	}
+}
+
+func AggLongFirst(name, fieldName string, options ...AggOption) Aggregation {
+	return firstLastAgg("longFirst", name, fieldName, options)
+}
+
+func AggLongLast(name, fieldName string, options ...AggOption) Aggregation {
+	return firstLastAgg("longLast", name, fieldName, options)
+}
+
+func AggDoubleFirst(name, fieldName string, options ...AggOption) Aggregation {
+	return firstLastAgg("doubleFirst", name, fieldName, options)
+}
+
+func AggDoubleLast(name, fieldName string, options ...AggOption) Aggregation {
+	return firstLastAgg("doubleLast", name, fieldName, options)
+}
+
+func AggFloatFirst(name, fieldName string, options ...AggOption) Aggregation {
+	return firstLastAgg("floatFirst", name, fieldName, options)
+}
+
+func AggFloatLast(name, fieldName string, options ...AggOption) Aggregation {
+	return firstLastAgg("floatLast", name, fieldName, options)
+}
+
+func AggStringFirst(name, fieldName string, options ...AggOption) Aggregation {
+	return firstLastAgg("stringFirst", name, fieldName, options)
+}
+
+func AggStringLast(name, fieldName string, options ...AggOption) Aggregation {
+	return firstLastAgg("stringLast", name, fieldName, options)
+}
+
+func AggStringAny(name, fieldName string, options ...AggOption) Aggregation {
+	return firstLastAgg("stringAny", name, fieldName, options)
+}
+
 func AggJavaScript(name, fnAggregate, fnCombine, fnReset string, fieldNames []string) Aggregation {
 	return Aggregation{
 		Type:        "javascript",
@@ -125,6 +289,46 @@ func AggHyperUnique(name, fieldName string) Aggregation {
 	}
 }
 
+func AggApproxHistogram(name, fieldName string, options ...AggOption) Aggregation {
+	agg := Aggregation{
+		Type:      "approxHistogram",
+		Name:      name,
+		FieldName: fieldName,
+	}
+	for _, opt := range options {
+		opt.apply(&agg)
+	}
+	return agg
+}
+
+// AggApproxHistogramFold merges the approximate histograms stored in the column.
+func AggApproxHistogramFold(name, fieldName string, options ...AggOption) Aggregation {
+	agg := Aggregation{
+		Type:      "approxHistogramFold",
+		Name:      name,
+		FieldName: fieldName,
+	}
+	for _, opt := range options {
+		opt.apply(&agg)
+	}
+	return agg
+}
+
+func AggFixedBucketsHistogram(name, fieldName string, lowerLimit, upperLimit float64, numBuckets int, options ...AggOption) Aggregation {
+	agg := Aggregation{
+		Type:       "fixedBucketsHistogram",
+		Name:       name,
+		FieldName:  fieldName,
+		LowerLimit: &lowerLimit,
+		UpperLimit: &upperLimit,
+		NumBuckets: numBuckets,
+	}
+	for _, opt := range options {
+		opt.apply(&agg)
+	}
+	return agg
+}
+
 func AggFiltered(aggFilter Filter, aggregation Aggregation) Aggregation {
 	return Aggregation{
 		Type:       "filtered",
@@ -133,10 +337,94 @@ func AggFiltered(aggFilter Filter, aggregation Aggregation) Aggregation {
 	}
 }
 
-func AggThetaSketch(name, fieldName string) Aggregation {
-	return Aggregation{
+func AggThetaSketch(name, fieldName string, options ...AggOption) Aggregation {
+	agg := Aggregation{
 		Type:      "thetaSketch",
 		Name:      name,
 		FieldName: fieldName,
 	}
+	for _, opt := range options {
+		opt.apply(&agg)
+	}
+	return agg
+}
+
+// AggHLLSketchBuild builds a HLL sketch from the raw values of the column.
+func AggHLLSketchBuild(name, fieldName string, options ...AggOption) Aggregation {
+	agg := Aggregation{
+		Type:      "HLLSketchBuild",
+		Name:      name,
+		FieldName: fieldName,
+	}
+	for _, opt := range options {
+		opt.apply(&agg)
+	}
+	return agg
+}
+
+// AggHLLSketchMerge merges the HLL sketches stored in the column.
+func AggHLLSketchMerge(name, fieldName string, options ...AggOption) Aggregation {
+	agg := Aggregation{
+		Type:      "HLLSketchMerge",
+		Name:      name,
+		FieldName: fieldName,
+	}
+	for _, opt := range options {
+		opt.apply(&agg)
+	}
+	return agg
+}
+
+func AggQuantilesDoublesSketch(name, fieldName string, options ...AggOption) Aggregation {
+	agg := Aggregation{
+		Type:      "quantilesDoublesSketch",
+		Name:      name,
+		FieldName: fieldName,
+	}
+	for _, opt := range options {
+		opt.apply(&agg)
+	}
+	return agg
+}
+
+// ---------------------------------
+// Helpers
+// ---------------------------------
+
+func firstLastAgg(aggType, name, fieldName string, options []AggOption) Aggregation {
+	agg := Aggregation{
+		Type:      aggType,
+		Name:      name,
+		FieldName: fieldName,
+	}
+	for _, opt := range options {
+		opt.apply(&agg)
+	}
+	return agg
+}
+
+// outputName returns the name under which the aggregation shows up in the results.
+func (a Aggregation) outputName() string {
+	if a.Type == "filtered" && a.Aggregator != nil && a.Name == "" {
+		return a.Aggregator.outputName()
+	}
+	return a.Name
+}
+
+// combiner returns how two finalized results of the aggregation are merged into one,
+// false if they can't be, e.g. the sketches which are finalized into estimates.
+func (a Aggregation) combiner() (func(x, y float64) float64, bool) {
+	switch a.Type {
+	case "count", "longSum", "doubleSum", "floatSum":
+		return func(x, y float64) float64 { return x + y }, true
+	case "longMin", "doubleMin", "floatMin":
+		return math.Min, true
+	case "longMax", "doubleMax", "floatMax":
+		return math.Max, true
+	case "filtered":
+		if a.Aggregator != nil {
+			return a.Aggregator.combiner()
+		}
+	}
+	return nil, false
 }
@@ -0,0 +1,61 @@
+package godruid
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAggregations(t *testing.T) {
+	Convey("TestAggregations", t, func() {
+		Convey("first, last and any", func() {
+			b, err := json.Marshal(AggStringLast("os", "os", MaxStringBytes(64), TimeColumn("updated")))
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, `{"type":"stringLast","name":"os","fieldName":"os","maxStringBytes":64,"timeColumn":"updated"}`)
+			b, err = json.Marshal(AggDoubleFirst("price", "price"))
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, `{"type":"doubleFirst","name":"price","fieldName":"price"}`)
+			So(AggStringAny("os", "os").Type, ShouldEqual, "stringAny")
+			So(AggLongLast("n", "n").Type, ShouldEqual, "longLast")
+		})
+
+		Convey("sketches", func() {
+			b, err := json.Marshal(AggThetaSketch("users", "user_id", Size(32768), ShouldFinalize(false), IsInputThetaSketch(true), ErrorBoundsStdDev(2)))
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, `{"type":"thetaSketch","name":"users","fieldName":"user_id","size":32768,"shouldFinalize":false,"isInputThetaSketch":true,"errorBoundsStdDev":2}`)
+			b, err = json.Marshal(AggHLLSketchBuild("users", "user_id", LgK(12), HLL8, Round(true)))
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, `{"type":"HLLSketchBuild","name":"users","fieldName":"user_id","lgK":12,"tgtHllType":"HLL_8","round":true}`)
+			So(AggHLLSketchMerge("users", "users_sketch").Type, ShouldEqual, "HLLSketchMerge")
+			b, err = json.Marshal(AggQuantilesDoublesSketch("latency", "latency", SketchK(256)))
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, `{"type":"quantilesDoublesSketch","name":"latency","fieldName":"latency","k":256}`)
+		})
+
+		Convey("float", func() {
+			for _, agg := range []Aggregation{AggFloatSum("x", "x"), AggFloatMin("x", "x"), AggFloatMax("x", "x")} {
+				_, ok := agg.combiner()
+				So(ok, ShouldBeTrue)
+			}
+			_, ok := AggFloatLast("x", "x").combiner()
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("unfinalized results", func() {
+			q := &QueryGroupBy{}
+			err := q.onResponse([]byte(`[{"version":"v1","timestamp":"2016-05-01T00:00:00.000Z","event":{
+				"os":"ios","last":{"lhs":1462060800000,"rhs":"11.2"},"price":{"lhs":1462060800000,"rhs":9.5},
+				"other":{"lhs":"a","rhs":1},"count":3}}]`))
+			So(err, ShouldBeNil)
+			So(q.QueryResult[0].Version, ShouldEqual, "v1")
+			So(q.QueryResult[0].Event, ShouldResemble, map[string]interface{}{
+				"os":    "ios",
+				"last":  "11.2",
+				"price": 9.5,
+				"other": map[string]interface{}{"lhs": "a", "rhs": 1.0},
+				"count": 3.0,
+			})
+		})
+	})
+}
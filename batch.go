@@ -0,0 +1,130 @@
+package godruid
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBatchWorkers is the pool size used by QueryBatch when no Workers option is given.
+const DefaultBatchWorkers = 8
+
+// BatchResult reports how a single query of a batch went.
+// The query result itself lands in the QueryResult field of the query.
+type BatchResult struct {
+	Query    Query
+	Err      error
+	Start    time.Time
+	Duration time.Duration
+}
+
+// BatchError collects the failed queries of a batch run in collect-all mode.
+type BatchError []BatchResult
+
+func (e BatchError) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, r := range e {
+		msgs = append(msgs, r.Err.Error())
+	}
+	return fmt.Sprintf("%d queries failed: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// ---------------------------------
+// Options
+// ---------------------------------
+
+type batchConfig struct {
+	workers  int
+	failFast bool
+}
+
+type BatchOption interface {
+	apply(*batchConfig)
+}
+
+// Workers bounds the number of queries running at the same time.
+type Workers int
+
+func (i Workers) apply(c *batchConfig) { c.workers = int(i) }
+
+// FailFast cancels the rest of the batch as soon as one query fails.
+type FailFast bool
+
+func (b FailFast) apply(c *batchConfig) { c.failFast = bool(b) }
+
+// ---------------------------------
+// Executor
+// ---------------------------------
+
+// QueryBatch runs the queries concurrently with a bounded worker pool.
+// The returned results are in the same order as queries.
+//
+// By default every query runs and the returned error is a BatchError of all the failed ones.
+// With FailFast(true) the first failure cancels the pending queries and is returned as is.
+func (c *Client) QueryBatch(ctx context.Context, queries []Query, options ...BatchOption) ([]BatchResult, error) {
+	conf := batchConfig{workers: DefaultBatchWorkers}
+	for _, opt := range options {
+		opt.apply(&conf)
+	}
+	if conf.workers <= 0 {
+		conf.workers = DefaultBatchWorkers
+	}
+	if conf.workers > len(queries) {
+		conf.workers = len(queries)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]BatchResult, len(queries))
+	var (
+		firstErr error
+		errOnce  sync.Once
+		wg       sync.WaitGroup
+	)
+	jobs := make(chan int)
+	for w := 0; w < conf.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				r := &results[i]
+				r.Query = queries[i]
+				if err := ctx.Err(); err != nil {
+					r.Err = err
+					continue
+				}
+				r.Start = time.Now()
+				r.Err = c.QueryContext(ctx, queries[i])
+				r.Duration = time.Since(r.Start)
+				if r.Err != nil && conf.failFast {
+					errOnce.Do(func() {
+						firstErr = r.Err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+	for i := range queries {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return results, firstErr
+	}
+	var failed BatchError
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	if len(failed) != 0 {
+		return results, failed
+	}
+	return results, nil
+}
@@ -0,0 +1,64 @@
+package godruid
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestQueryBatch(t *testing.T) {
+	Convey("TestQueryBatch", t, func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			if strings.Contains(string(body), `"bad"`) {
+				http.Error(w, "no such datasource", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`[{"timestamp":"2016-05-01T00:00:00.000Z","result":{"count":1}}]`))
+		}))
+		defer server.Close()
+
+		client := Client{Url: server.URL}
+
+		Convey("collect all", func() {
+			queries := []Query{
+				&QueryTimeseries{DataSource: "good", Granularity: GranAll},
+				&QueryTimeseries{DataSource: "bad", Granularity: GranAll},
+				&QueryTimeseries{DataSource: "good", Granularity: GranAll},
+			}
+			results, err := client.QueryBatch(context.Background(), queries, Workers(2))
+			So(results, ShouldHaveLength, 3)
+			So(err, ShouldNotBeNil)
+			So(err.(BatchError), ShouldHaveLength, 1)
+			So(results[0].Err, ShouldBeNil)
+			So(results[1].Err, ShouldNotBeNil)
+			So(results[2].Err, ShouldBeNil)
+			So(queries[0].(*QueryTimeseries).QueryResult, ShouldHaveLength, 1)
+			So(queries[2].(*QueryTimeseries).QueryResult, ShouldHaveLength, 1)
+		})
+
+		Convey("fail fast", func() {
+			queries := []Query{&QueryTimeseries{DataSource: "bad", Granularity: GranAll}}
+			for i := 0; i < 10; i++ {
+				queries = append(queries, &QueryTimeseries{DataSource: "good", Granularity: GranAll})
+			}
+			results, err := client.QueryBatch(context.Background(), queries, Workers(1), FailFast(true))
+			So(err, ShouldNotBeNil)
+			So(results[0].Err, ShouldEqual, err)
+			So(results[10].Err, ShouldEqual, context.Canceled)
+		})
+
+		Convey("cancelled context", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			results, err := client.QueryBatch(ctx, []Query{&QueryTimeseries{DataSource: "good", Granularity: GranAll}})
+			So(err, ShouldNotBeNil)
+			So(results[0].Err, ShouldEqual, context.Canceled)
+		})
+	})
+}
@@ -0,0 +1,918 @@
+package godruid
+
+import (
+	"fmt"
+	"math"
+)
+
+// The fluent builders fill the query structs step by step, like
+//
+//	query, err := NewGroupBy("events").Last("P7D").Granularity(GranDay).Dims("os").
+//		Count("count").Sum("revenue", "revenue").Where(FilterSelector("app", "a")).
+//		OrderBy("revenue", LimitDesc).Limit(10).Build()
+//
+// Every builder is started by New followed by the name of its query type.
+// The first error of a chain is reported by Build. Build also adds the aggregations which
+// the post aggregations refer to but are not defined. They are taken from the Catalog of the
+// datasource when it has them, otherwise "count" becomes a count, a hyperUniqueCardinality
+// target a hyperUnique, and any other name a doubleSum over the same field.
+
+// ---------------------------------
+// Shared parts
+// ---------------------------------
+
+// builder holds the parts shared by the query builders, each one exposes the methods of the parts its query has.
+type builder struct {
+	dataSource string
+	intervals  []string
+	gran       Granularity
+	filter     *Filter
+	aggs       []Aggregation
+	postAggs   []PostAggregation
+	catalog    AggregatorCatalog
+	context    map[string]interface{}
+	err        error
+}
+
+func newBuilder(dataSource string) builder {
+	return builder{dataSource: dataSource, gran: GranAll}
+}
+
+func (b *builder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+func (b *builder) addIntervals(intervals []string) {
+	for _, s := range intervals {
+		if _, err := ParseInterval(s); err != nil {
+			b.fail(err)
+			return
+		}
+	}
+	b.intervals = append(b.intervals, intervals...)
+}
+
+func (b *builder) addLast(period string) {
+	iv, err := IntervalLast(period)
+	if err != nil {
+		b.fail(err)
+		return
+	}
+	b.intervals = append(b.intervals, iv.String())
+}
+
+func (b *builder) setGranularity(gran Granularity) {
+	if err := ValidateGranularity(gran); err != nil {
+		b.fail(err)
+		return
+	}
+	b.gran = gran
+}
+
+func (b *builder) addContext(key string, value interface{}) {
+	if b.context == nil {
+		b.context = map[string]interface{}{}
+	}
+	b.context[key] = value
+}
+
+// check returns the first error of the chain or of the common parts.
+func (b *builder) check(needIntervals bool) error {
+	if b.err != nil {
+		return b.err
+	}
+	if b.dataSource == "" {
+		return fmt.Errorf("missing dataSource")
+	}
+	if needIntervals && len(b.intervals) == 0 {
+		return fmt.Errorf("missing intervals")
+	}
+	return nil
+}
+
+// aggregations returns the aggregations with the ones referred by the post aggregations added.
+func (b *builder) aggregations() ([]Aggregation, error) {
+	aggs := append([]Aggregation(nil), b.aggs...)
+	defined := map[string]bool{}
+	for _, agg := range aggs {
+		name := agg.outputName()
+		if defined[name] {
+			return nil, fmt.Errorf("duplicate aggregation %q", name)
+		}
+		defined[name] = true
+	}
+	for _, pa := range b.postAggs {
+		if pa.Name == "" {
+			return nil, fmt.Errorf("post aggregation of type %q without name", pa.Type)
+		}
+		if defined[pa.Name] {
+			return nil, fmt.Errorf("duplicate aggregation %q", pa.Name)
+		}
+		if err := pa.Validate(); err != nil {
+			return nil, err
+		}
+		defined[pa.Name] = true
+	}
+	for _, pa := range b.postAggs {
+		for _, ref := range pa.fieldRefs() {
+			if ref.FieldName == "" || defined[ref.FieldName] {
+				continue
+			}
+			defined[ref.FieldName] = true
+			if agg, err := b.catalog.Agg(ref.FieldName, ref.FieldName); err == nil {
+				aggs = append(aggs, agg)
+				continue
+			}
+			switch {
+			case ref.Type == "hyperUniqueCardinality":
+				aggs = append(aggs, AggHyperUnique(ref.FieldName, ref.FieldName))
+			case ref.FieldName == "count":
+				aggs = append(aggs, AggCount(ref.FieldName))
+			default:
+				aggs = append(aggs, AggDoubleSum(ref.FieldName, ref.FieldName))
+			}
+		}
+	}
+	return aggs, nil
+}
+
+// ---------------------------------
+// GroupBy
+// ---------------------------------
+
+type GroupByBuilder struct {
+	builder
+	dims    []DimSpec
+	having  *Having
+	limit   int
+	columns []Column
+}
+
+func NewGroupBy(dataSource string) *GroupByBuilder {
+	return &GroupByBuilder{builder: newBuilder(dataSource)}
+}
+
+func (b *GroupByBuilder) Dims(dims ...DimSpec) *GroupByBuilder {
+	b.dims = append(b.dims, dims...)
+	return b
+}
+
+// Having adds a having spec, the havings of the chain are and-ed.
+func (b *GroupByBuilder) Having(having *Having) *GroupByBuilder {
+	b.having = HavingAnd(b.having, having)
+	return b
+}
+
+// OrderBy adds a column of the limitSpec, direction is LimitAsc or LimitDesc.
+func (b *GroupByBuilder) OrderBy(dimension, direction string) *GroupByBuilder {
+	if direction != LimitAsc && direction != LimitDesc {
+		b.fail(fmt.Errorf("invalid order direction %q", direction))
+		return b
+	}
+	b.columns = append(b.columns, Column{Dimension: dimension, Direction: direction})
+	return b
+}
+
+func (b *GroupByBuilder) Limit(limit int) *GroupByBuilder {
+	if limit <= 0 {
+		b.fail(fmt.Errorf("invalid limit %d", limit))
+		return b
+	}
+	b.limit = limit
+	return b
+}
+
+func (b *GroupByBuilder) Intervals(intervals ...string) *GroupByBuilder {
+	b.addIntervals(intervals)
+	return b
+}
+
+func (b *GroupByBuilder) Interval(ivs ...Interval) *GroupByBuilder {
+	b.addIntervals(Intervals(ivs...))
+	return b
+}
+
+// Last adds the interval of the ISO-8601 period ending now.
+func (b *GroupByBuilder) Last(period string) *GroupByBuilder {
+	b.addLast(period)
+	return b
+}
+
+func (b *GroupByBuilder) Granularity(gran Granularity) *GroupByBuilder {
+	b.setGranularity(gran)
+	return b
+}
+
+// Where adds a filter, the filters of the chain are and-ed.
+func (b *GroupByBuilder) Where(filter *Filter) *GroupByBuilder {
+	b.filter = FilterAnd(b.filter, filter)
+	return b
+}
+
+func (b *GroupByBuilder) Agg(aggs ...Aggregation) *GroupByBuilder {
+	b.aggs = append(b.aggs, aggs...)
+	return b
+}
+
+func (b *GroupByBuilder) Count(name string) *GroupByBuilder {
+	return b.Agg(AggCount(name))
+}
+
+// Sum adds a doubleSum aggregation.
+func (b *GroupByBuilder) Sum(name, fieldName string) *GroupByBuilder {
+	return b.Agg(AggDoubleSum(name, fieldName))
+}
+
+func (b *GroupByBuilder) LongSum(name, fieldName string) *GroupByBuilder {
+	return b.Agg(AggLongSum(name, fieldName))
+}
+
+// Min adds a doubleMin aggregation.
+func (b *GroupByBuilder) Min(name, fieldName string) *GroupByBuilder {
+	return b.Agg(AggDoubleMin(name, fieldName))
+}
+
+// Max adds a doubleMax aggregation.
+func (b *GroupByBuilder) Max(name, fieldName string) *GroupByBuilder {
+	return b.Agg(AggDoubleMax(name, fieldName))
+}
+
+func (b *GroupByBuilder) HyperUnique(name, fieldName string) *GroupByBuilder {
+	return b.Agg(AggHyperUnique(name, fieldName))
+}
+
+func (b *GroupByBuilder) PostAgg(postAggs ...PostAggregation) *GroupByBuilder {
+	b.postAggs = append(b.postAggs, postAggs...)
+	return b
+}
+
+// Catalog gives the aggregations of the metrics which the post aggregations refer to
+// without defining them, in place of the default ones, see Client.AggregatorCatalog.
+func (b *GroupByBuilder) Catalog(catalog AggregatorCatalog) *GroupByBuilder {
+	b.catalog = catalog
+	return b
+}
+
+func (b *GroupByBuilder) Context(key string, value interface{}) *GroupByBuilder {
+	b.addContext(key, value)
+	return b
+}
+
+func (b *GroupByBuilder) Build() (*QueryGroupBy, error) {
+	if err := b.check(true); err != nil {
+		return nil, err
+	}
+	if len(b.dims) == 0 {
+		return nil, fmt.Errorf("groupBy query without dimensions")
+	}
+	aggs, err := b.aggregations()
+	if err != nil {
+		return nil, err
+	}
+	q := &QueryGroupBy{
+		DataSource:       b.dataSource,
+		Dimensions:       b.dims,
+		Granularity:      b.gran,
+		Having:           b.having,
+		Filter:           b.filter,
+		Aggregations:     aggs,
+		PostAggregations: b.postAggs,
+		Intervals:        b.intervals,
+		Context:          b.context,
+	}
+	if b.limit > 0 || len(b.columns) != 0 {
+		limit := b.limit
+		if limit == 0 {
+			// The default limit of Druid.
+			limit = math.MaxInt32
+		}
+		q.LimitSpec = LimitDefault(limit, b.columns...)
+	}
+	q.setup()
+	return q, nil
+}
+
+// ---------------------------------
+// Timeseries
+// ---------------------------------
+
+type TimeseriesBuilder struct {
+	builder
+	descending bool
+}
+
+func NewTimeseries(dataSource string) *TimeseriesBuilder {
+	return &TimeseriesBuilder{builder: newBuilder(dataSource)}
+}
+
+func (b *TimeseriesBuilder) Descending() *TimeseriesBuilder {
+	b.descending = true
+	return b
+}
+
+func (b *TimeseriesBuilder) Intervals(intervals ...string) *TimeseriesBuilder {
+	b.addIntervals(intervals)
+	return b
+}
+
+func (b *TimeseriesBuilder) Interval(ivs ...Interval) *TimeseriesBuilder {
+	b.addIntervals(Intervals(ivs...))
+	return b
+}
+
+// Last adds the interval of the ISO-8601 period ending now.
+func (b *TimeseriesBuilder) Last(period string) *TimeseriesBuilder {
+	b.addLast(period)
+	return b
+}
+
+func (b *TimeseriesBuilder) Granularity(gran Granularity) *TimeseriesBuilder {
+	b.setGranularity(gran)
+	return b
+}
+
+// Where adds a filter, the filters of the chain are and-ed.
+func (b *TimeseriesBuilder) Where(filter *Filter) *TimeseriesBuilder {
+	b.filter = FilterAnd(b.filter, filter)
+	return b
+}
+
+func (b *TimeseriesBuilder) Agg(aggs ...Aggregation) *TimeseriesBuilder {
+	b.aggs = append(b.aggs, aggs...)
+	return b
+}
+
+func (b *TimeseriesBuilder) Count(name string) *TimeseriesBuilder {
+	return b.Agg(AggCount(name))
+}
+
+// Sum adds a doubleSum aggregation.
+func (b *TimeseriesBuilder) Sum(name, fieldName string) *TimeseriesBuilder {
+	return b.Agg(AggDoubleSum(name, fieldName))
+}
+
+func (b *TimeseriesBuilder) LongSum(name, fieldName string) *TimeseriesBuilder {
+	return b.Agg(AggLongSum(name, fieldName))
+}
+
+// Min adds a doubleMin aggregation.
+func (b *TimeseriesBuilder) Min(name, fieldName string) *TimeseriesBuilder {
+	return b.Agg(AggDoubleMin(name, fieldName))
+}
+
+// Max adds a doubleMax aggregation.
+func (b *TimeseriesBuilder) Max(name, fieldName string) *TimeseriesBuilder {
+	return b.Agg(AggDoubleMax(name, fieldName))
+}
+
+func (b *TimeseriesBuilder) HyperUnique(name, fieldName string) *TimeseriesBuilder {
+	return b.Agg(AggHyperUnique(name, fieldName))
+}
+
+func (b *TimeseriesBuilder) PostAgg(postAggs ...PostAggregation) *TimeseriesBuilder {
+	b.postAggs = append(b.postAggs, postAggs...)
+	return b
+}
+
+// Catalog gives the aggregations of the metrics which the post aggregations refer to
+// without defining them, in place of the default ones, see Client.AggregatorCatalog.
+func (b *TimeseriesBuilder) Catalog(catalog AggregatorCatalog) *TimeseriesBuilder {
+	b.catalog = catalog
+	return b
+}
+
+func (b *TimeseriesBuilder) Context(key string, value interface{}) *TimeseriesBuilder {
+	b.addContext(key, value)
+	return b
+}
+
+func (b *TimeseriesBuilder) Build() (*QueryTimeseries, error) {
+	if err := b.check(true); err != nil {
+		return nil, err
+	}
+	aggs, err := b.aggregations()
+	if err != nil {
+		return nil, err
+	}
+	q := &QueryTimeseries{
+		DataSource:       b.dataSource,
+		Descending:       b.descending,
+		Intervals:        b.intervals,
+		Granularity:      b.gran,
+		Filter:           b.filter,
+		Aggregations:     aggs,
+		PostAggregations: b.postAggs,
+		Context:          b.context,
+	}
+	q.setup()
+	return q, nil
+}
+
+// ---------------------------------
+// TopN
+// ---------------------------------
+
+type TopNBuilder struct {
+	builder
+	dim       DimSpec
+	threshold int
+	metric    *TopNMetric
+}
+
+func NewTopN(dataSource string) *TopNBuilder {
+	return &TopNBuilder{builder: newBuilder(dataSource)}
+}
+
+func (b *TopNBuilder) Dim(dim DimSpec) *TopNBuilder {
+	b.dim = dim
+	return b
+}
+
+// OrderBy ranks the results by the numeric metric, descending.
+func (b *TopNBuilder) OrderBy(metric string) *TopNBuilder {
+	b.metric = TopNMetricNumeric(metric)
+	return b
+}
+
+// Metric sets any TopNMetric spec.
+func (b *TopNBuilder) Metric(metric *TopNMetric) *TopNBuilder {
+	b.metric = metric
+	return b
+}
+
+func (b *TopNBuilder) Threshold(threshold int) *TopNBuilder {
+	if threshold <= 0 {
+		b.fail(fmt.Errorf("invalid threshold %d", threshold))
+		return b
+	}
+	b.threshold = threshold
+	return b
+}
+
+func (b *TopNBuilder) Intervals(intervals ...string) *TopNBuilder {
+	b.addIntervals(intervals)
+	return b
+}
+
+func (b *TopNBuilder) Interval(ivs ...Interval) *TopNBuilder {
+	b.addIntervals(Intervals(ivs...))
+	return b
+}
+
+// Last adds the interval of the ISO-8601 period ending now.
+func (b *TopNBuilder) Last(period string) *TopNBuilder {
+	b.addLast(period)
+	return b
+}
+
+func (b *TopNBuilder) Granularity(gran Granularity) *TopNBuilder {
+	b.setGranularity(gran)
+	return b
+}
+
+// Where adds a filter, the filters of the chain are and-ed.
+func (b *TopNBuilder) Where(filter *Filter) *TopNBuilder {
+	b.filter = FilterAnd(b.filter, filter)
+	return b
+}
+
+func (b *TopNBuilder) Agg(aggs ...Aggregation) *TopNBuilder {
+	b.aggs = append(b.aggs, aggs...)
+	return b
+}
+
+func (b *TopNBuilder) Count(name string) *TopNBuilder {
+	return b.Agg(AggCount(name))
+}
+
+// Sum adds a doubleSum aggregation.
+func (b *TopNBuilder) Sum(name, fieldName string) *TopNBuilder {
+	return b.Agg(AggDoubleSum(name, fieldName))
+}
+
+func (b *TopNBuilder) LongSum(name, fieldName string) *TopNBuilder {
+	return b.Agg(AggLongSum(name, fieldName))
+}
+
+// Min adds a doubleMin aggregation.
+func (b *TopNBuilder) Min(name, fieldName string) *TopNBuilder {
+	return b.Agg(AggDoubleMin(name, fieldName))
+}
+
+// Max adds a doubleMax aggregation.
+func (b *TopNBuilder) Max(name, fieldName string) *TopNBuilder {
+	return b.Agg(AggDoubleMax(name, fieldName))
+}
+
+func (b *TopNBuilder) HyperUnique(name, fieldName string) *TopNBuilder {
+	return b.Agg(AggHyperUnique(name, fieldName))
+}
+
+func (b *TopNBuilder) PostAgg(postAggs ...PostAggregation) *TopNBuilder {
+	b.postAggs = append(b.postAggs, postAggs...)
+	return b
+}
+
+// Catalog gives the aggregations of the metrics which the post aggregations refer to
+// without defining them, in place of the default ones, see Client.AggregatorCatalog.
+func (b *TopNBuilder) Catalog(catalog AggregatorCatalog) *TopNBuilder {
+	b.catalog = catalog
+	return b
+}
+
+func (b *TopNBuilder) Context(key string, value interface{}) *TopNBuilder {
+	b.addContext(key, value)
+	return b
+}
+
+func (b *TopNBuilder) Build() (*QueryTopN, error) {
+	if err := b.check(true); err != nil {
+		return nil, err
+	}
+	switch {
+	case b.dim == nil:
+		return nil, fmt.Errorf("topN query without dimension")
+	case b.metric == nil:
+		return nil, fmt.Errorf("topN query without metric")
+	case b.threshold == 0:
+		return nil, fmt.Errorf("topN query without threshold")
+	}
+	aggs, err := b.aggregations()
+	if err != nil {
+		return nil, err
+	}
+	q := &QueryTopN{
+		DataSource:       b.dataSource,
+		Granularity:      b.gran,
+		Dimension:        b.dim,
+		Threshold:        b.threshold,
+		Metric:           b.metric,
+		Filter:           b.filter,
+		Aggregations:     aggs,
+		PostAggregations: b.postAggs,
+		Intervals:        b.intervals,
+		Context:          b.context,
+	}
+	q.setup()
+	return q, nil
+}
+
+// ---------------------------------
+// Search
+// ---------------------------------
+
+type SearchBuilder struct {
+	builder
+	dims  []string
+	query *SearchQuery
+	sort  *SearchSort
+	limit int
+}
+
+func NewSearch(dataSource string) *SearchBuilder {
+	return &SearchBuilder{builder: newBuilder(dataSource)}
+}
+
+func (b *SearchBuilder) Dims(dims ...string) *SearchBuilder {
+	b.dims = append(b.dims, dims...)
+	return b
+}
+
+func (b *SearchBuilder) Query(query *SearchQuery) *SearchBuilder {
+	b.query = query
+	return b
+}
+
+// Contains searches the values containing value, case insensitive.
+func (b *SearchBuilder) Contains(value string) *SearchBuilder {
+	return b.Query(SearchQueryInsensitiveContains(value))
+}
+
+func (b *SearchBuilder) Sort(sort *SearchSort) *SearchBuilder {
+	b.sort = sort
+	return b
+}
+
+func (b *SearchBuilder) Limit(limit int) *SearchBuilder {
+	if limit <= 0 {
+		b.fail(fmt.Errorf("invalid limit %d", limit))
+		return b
+	}
+	b.limit = limit
+	return b
+}
+
+func (b *SearchBuilder) Intervals(intervals ...string) *SearchBuilder {
+	b.addIntervals(intervals)
+	return b
+}
+
+func (b *SearchBuilder) Interval(ivs ...Interval) *SearchBuilder {
+	b.addIntervals(Intervals(ivs...))
+	return b
+}
+
+// Last adds the interval of the ISO-8601 period ending now.
+func (b *SearchBuilder) Last(period string) *SearchBuilder {
+	b.addLast(period)
+	return b
+}
+
+func (b *SearchBuilder) Granularity(gran Granularity) *SearchBuilder {
+	b.setGranularity(gran)
+	return b
+}
+
+// Where adds a filter, the filters of the chain are and-ed.
+func (b *SearchBuilder) Where(filter *Filter) *SearchBuilder {
+	b.filter = FilterAnd(b.filter, filter)
+	return b
+}
+
+func (b *SearchBuilder) Context(key string, value interface{}) *SearchBuilder {
+	b.addContext(key, value)
+	return b
+}
+
+func (b *SearchBuilder) Build() (*QuerySearch, error) {
+	if err := b.check(true); err != nil {
+		return nil, err
+	}
+	if b.query == nil {
+		return nil, fmt.Errorf("search query without query spec")
+	}
+	q := &QuerySearch{
+		DataSource:       b.dataSource,
+		Granularity:      b.gran,
+		Filter:           b.filter,
+		Limit:            b.limit,
+		Intervals:        b.intervals,
+		SearchDimensions: b.dims,
+		Query:            b.query,
+		Sort:             b.sort,
+		Context:          b.context,
+	}
+	q.setup()
+	return q, nil
+}
+
+// ---------------------------------
+// Select
+// ---------------------------------
+
+type SelectBuilder struct {
+	builder
+	dims       []string
+	metrics    []string
+	descending bool
+	paging     PagingSpec
+}
+
+func NewSelect(dataSource string) *SelectBuilder {
+	return &SelectBuilder{builder: newBuilder(dataSource), paging: PagingSpec{PagingIdentifiers: PagingIdEmpty{}}}
+}
+
+func (b *SelectBuilder) Dims(dims ...string) *SelectBuilder {
+	b.dims = append(b.dims, dims...)
+	return b
+}
+
+func (b *SelectBuilder) Metrics(metrics ...string) *SelectBuilder {
+	b.metrics = append(b.metrics, metrics...)
+	return b
+}
+
+func (b *SelectBuilder) Descending() *SelectBuilder {
+	b.descending = true
+	return b
+}
+
+// Page sets the paging spec, pagingIdentifiers could be nil for the first page.
+func (b *SelectBuilder) Page(pagingIdentifiers PagingIdentifiers, threshold int) *SelectBuilder {
+	if threshold <= 0 {
+		b.fail(fmt.Errorf("invalid threshold %d", threshold))
+		return b
+	}
+	if pagingIdentifiers == nil {
+		pagingIdentifiers = PagingIdEmpty{}
+	}
+	b.paging = PagingSpec{PagingIdentifiers: pagingIdentifiers, Threshold: threshold}
+	return b
+}
+
+func (b *SelectBuilder) Intervals(intervals ...string) *SelectBuilder {
+	b.addIntervals(intervals)
+	return b
+}
+
+func (b *SelectBuilder) Interval(ivs ...Interval) *SelectBuilder {
+	b.addIntervals(Intervals(ivs...))
+	return b
+}
+
+// Last adds the interval of the ISO-8601 period ending now.
+func (b *SelectBuilder) Last(period string) *SelectBuilder {
+	b.addLast(period)
+	return b
+}
+
+func (b *SelectBuilder) Granularity(gran Granularity) *SelectBuilder {
+	b.setGranularity(gran)
+	return b
+}
+
+// Where adds a filter, the filters of the chain are and-ed.
+func (b *SelectBuilder) Where(filter *Filter) *SelectBuilder {
+	b.filter = FilterAnd(b.filter, filter)
+	return b
+}
+
+func (b *SelectBuilder) Context(key string, value interface{}) *SelectBuilder {
+	b.addContext(key, value)
+	return b
+}
+
+func (b *SelectBuilder) Build() (*QuerySelect, error) {
+	if err := b.check(true); err != nil {
+		return nil, err
+	}
+	if b.paging.Threshold == 0 {
+		return nil, fmt.Errorf("select query without paging threshold")
+	}
+	q := &QuerySelect{
+		DataSource:  b.dataSource,
+		Intervals:   b.intervals,
+		Descending:  b.descending,
+		Filter:      b.filter,
+		Dimensions:  b.dims,
+		Metrics:     b.metrics,
+		PagingSpec:  b.paging,
+		Granularity: b.gran,
+		Context:     b.context,
+	}
+	q.setup()
+	return q, nil
+}
+
+// ---------------------------------
+// SegmentMetadata
+// ---------------------------------
+
+type SegmentMetadataBuilder struct {
+	builder
+	toInclude     *ToInclude
+	merge         bool
+	analysisTypes []AnalysisType
+	lenient       bool
+}
+
+func NewSegmentMetadata(dataSource string) *SegmentMetadataBuilder {
+	return &SegmentMetadataBuilder{builder: newBuilder(dataSource)}
+}
+
+func (b *SegmentMetadataBuilder) ToInclude(toInclude *ToInclude) *SegmentMetadataBuilder {
+	b.toInclude = toInclude
+	return b
+}
+
+func (b *SegmentMetadataBuilder) Merge() *SegmentMetadataBuilder {
+	b.merge = true
+	return b
+}
+
+func (b *SegmentMetadataBuilder) AnalysisTypes(analysisTypes ...AnalysisType) *SegmentMetadataBuilder {
+	b.analysisTypes = append(b.analysisTypes, analysisTypes...)
+	return b
+}
+
+func (b *SegmentMetadataBuilder) LenientAggregatorMerge() *SegmentMetadataBuilder {
+	b.lenient = true
+	return b
+}
+
+func (b *SegmentMetadataBuilder) Intervals(intervals ...string) *SegmentMetadataBuilder {
+	b.addIntervals(intervals)
+	return b
+}
+
+func (b *SegmentMetadataBuilder) Interval(ivs ...Interval) *SegmentMetadataBuilder {
+	b.addIntervals(Intervals(ivs...))
+	return b
+}
+
+// Last adds the interval of the ISO-8601 period ending now.
+func (b *SegmentMetadataBuilder) Last(period string) *SegmentMetadataBuilder {
+	b.addLast(period)
+	return b
+}
+
+func (b *SegmentMetadataBuilder) Context(key string, value interface{}) *SegmentMetadataBuilder {
+	b.addContext(key, value)
+	return b
+}
+
+func (b *SegmentMetadataBuilder) Build() (*QuerySegmentMetadata, error) {
+	if err := b.check(false); err != nil {
+		return nil, err
+	}
+	q := &QuerySegmentMetadata{
+		DataSource:             b.dataSource,
+		Intervals:              b.intervals,
+		ToInclude:              b.toInclude,
+		Context:                b.context,
+		Merge:                  b.merge,
+		AnalysisTypes:          b.analysisTypes,
+		LenientAggregatorMerge: b.lenient,
+	}
+	q.setup()
+	return q, nil
+}
+
+// ---------------------------------
+// TimeBoundary
+// ---------------------------------
+
+type TimeBoundaryBuilder struct {
+	builder
+	bound string
+}
+
+func NewTimeBoundary(dataSource string) *TimeBoundaryBuilder {
+	return &TimeBoundaryBuilder{builder: newBuilder(dataSource)}
+}
+
+// Bound is "minTime" or "maxTime", both are returned by default.
+func (b *TimeBoundaryBuilder) Bound(bound string) *TimeBoundaryBuilder {
+	if bound != "minTime" && bound != "maxTime" {
+		b.fail(fmt.Errorf("invalid bound %q", bound))
+		return b
+	}
+	b.bound = bound
+	return b
+}
+
+func (b *TimeBoundaryBuilder) Intervals(intervals ...string) *TimeBoundaryBuilder {
+	b.addIntervals(intervals)
+	return b
+}
+
+func (b *TimeBoundaryBuilder) Interval(ivs ...Interval) *TimeBoundaryBuilder {
+	b.addIntervals(Intervals(ivs...))
+	return b
+}
+
+// Last adds the interval of the ISO-8601 period ending now.
+func (b *TimeBoundaryBuilder) Last(period string) *TimeBoundaryBuilder {
+	b.addLast(period)
+	return b
+}
+
+func (b *TimeBoundaryBuilder) Context(key string, value interface{}) *TimeBoundaryBuilder {
+	b.addContext(key, value)
+	return b
+}
+
+func (b *TimeBoundaryBuilder) Build() (*QueryTimeBoundary, error) {
+	if err := b.check(false); err != nil {
+		return nil, err
+	}
+	q := &QueryTimeBoundary{
+		DataSource: b.dataSource,
+		Intervals:  b.intervals,
+		Bound:      b.bound,
+		Context:    b.context,
+	}
+	q.setup()
+	return q, nil
+}
+
+// ---------------------------------
+// DataSourceMetadata
+// ---------------------------------
+
+type DataSourceMetadataBuilder struct {
+	builder
+}
+
+func NewDataSourceMetadata(dataSource string) *DataSourceMetadataBuilder {
+	return &DataSourceMetadataBuilder{builder: newBuilder(dataSource)}
+}
+
+func (b *DataSourceMetadataBuilder) Context(key string, value interface{}) *DataSourceMetadataBuilder {
+	b.addContext(key, value)
+	return b
+}
+
+func (b *DataSourceMetadataBuilder) Build() (*QueryDataSourceMetadata, error) {
+	if err := b.check(false); err != nil {
+		return nil, err
+	}
+	q := &QueryDataSourceMetadata{
+		DataSource: b.dataSource,
+		Context:    b.context,
+	}
+	q.setup()
+	return q, nil
+}
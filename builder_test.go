@@ -0,0 +1,82 @@
+package godruid
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBuilder(t *testing.T) {
+	Convey("TestBuilder", t, func() {
+		Convey("groupBy", func() {
+			query, err := NewGroupBy("events_agg").
+				Intervals("2016-05-01T00:00/2016-05-01T01").
+				Dims("attribution_network").
+				LongSum("revenue", "dimension_sum").
+				Count("count").
+				Where(FilterSelector("app_id", "1")).
+				Where(FilterSelector("attribution_network_key", nil)).
+				PostAgg(PostAggArithmetic("Revenue/Event", "/", []PostAggregation{
+					PostAggFieldAccessor("revenue"),
+					PostAggFieldAccessor("count")})).
+				OrderBy("revenue", LimitDesc).
+				Limit(5).
+				Build()
+			So(err, ShouldBeNil)
+			So(query.QueryType, ShouldEqual, "groupBy")
+			So(query.Granularity, ShouldEqual, GranAll)
+			So(query.Aggregations, ShouldResemble, []Aggregation{AggLongSum("revenue", "dimension_sum"), AggCount("count")})
+			So(query.Filter.Type, ShouldEqual, "and")
+			So(query.LimitSpec, ShouldResemble, LimitDefault(5, Column{Dimension: "revenue", Direction: LimitDesc}))
+		})
+
+		Convey("errors", func() {
+			_, err := NewGroupBy("ds").Intervals("yesterday").Dims("os").Build()
+			So(err, ShouldNotBeNil)
+			_, err = NewGroupBy("ds").Intervals("2016-05-01/P1D").Build()
+			So(err, ShouldNotBeNil)
+			_, err = NewTopN("ds").Intervals("2016-05-01/P1D").Dim("os").OrderBy("count").Build()
+			So(err, ShouldNotBeNil)
+			_, err = NewTimeseries("ds").Intervals("2016-05-01/P1D").Granularity(GranPeriod("1D")).Build()
+			So(err, ShouldNotBeNil)
+			_, err = NewTimeseries("ds").Intervals("2016-05-01/P1D").Count("n").Count("n").Build()
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("referenced aggregations", func() {
+			query, err := NewTimeseries("ds").Intervals("2016-05-01/P1D").
+				PostAgg(PostAggArithmetic("per_user", "/", []PostAggregation{
+					PostAggArithmetic("avg", "/", []PostAggregation{PostAggFieldAccessor("revenue"), PostAggFieldAccessor("count")}),
+					PostAggFieldHyperUnique("users")})).
+				Build()
+			So(err, ShouldBeNil)
+			So(query.Aggregations, ShouldResemble, []Aggregation{AggDoubleSum("revenue", "revenue"), AggCount("count"), AggHyperUnique("users", "users")})
+		})
+
+		Convey("topN", func() {
+			catalog := AggregatorCatalog{"revenue": AggLongSum("revenue", "revenue")}
+			query, err := NewTopN("ds").Intervals("2016-05-01/P1D").Dim("os").Catalog(catalog).
+				PostAgg(PostAggArithmetic("per_user", "/", []PostAggregation{PostAggFieldAccessor("revenue"), PostAggFieldHyperUnique("users")})).
+				OrderBy("per_user").Threshold(10).Build()
+			So(err, ShouldBeNil)
+			So(query.Aggregations, ShouldResemble, []Aggregation{AggLongSum("revenue", "revenue"), AggHyperUnique("users", "users")})
+		})
+
+		Convey("others", func() {
+			tb, err := NewTimeBoundary("ds").Intervals("2016-05-01/P1D").Bound("maxTime").Context("priority", 1).Build()
+			So(err, ShouldBeNil)
+			So(tb.Intervals, ShouldResemble, IntervalList{"2016-05-01/P1D"})
+			So(tb.Context, ShouldResemble, map[string]interface{}{"priority": 1})
+			_, err = NewTimeBoundary("ds").Bound("midTime").Build()
+			So(err, ShouldNotBeNil)
+
+			sel, err := NewSelect("ds").Intervals("2016-05-01/P1D").Where(FilterSelector("os", "ios")).Page(nil, 10).Build()
+			So(err, ShouldBeNil)
+			So(sel.Granularity, ShouldEqual, GranAll)
+			So(sel.Filter, ShouldResemble, FilterSelector("os", "ios"))
+
+			_, err = NewSearch("").Intervals("2016-05-01/P1D").Contains("x").Build()
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
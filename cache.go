@@ -0,0 +1,167 @@
+package godruid
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Cache stores the raw query results by QueryKey.
+// The implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the cached value of key, false if missing or expired.
+	Get(key string) ([]byte, bool)
+	// Set stores value under key, ttl 0 means the value never expires.
+	Set(key string, value []byte, ttl time.Duration)
+}
+
+// CachePolicy decides how long the results of a query are cached.
+type CachePolicy struct {
+	// HistoricalTTL applies to the queries whose intervals all end in the past, 0 means forever.
+	HistoricalTTL time.Duration
+	// RecentTTL applies to the queries whose intervals touch now, 0 means not cached.
+	RecentTTL time.Duration
+	// MaxResultBytes skips caching the results larger than it, 0 means no limit.
+	MaxResultBytes int
+}
+
+// ttl returns how long the result of query could be cached, false if it should not be cached.
+func (p CachePolicy) ttl(query Query, result []byte, now time.Time) (time.Duration, bool) {
+	if p.MaxResultBytes > 0 && len(result) > p.MaxResultBytes {
+		return 0, false
+	}
+	if touchesNow(query, now) {
+		return p.RecentTTL, p.RecentTTL > 0
+	}
+	return p.HistoricalTTL, true
+}
+
+// touchesNow reports whether any interval of the query could still get new data.
+// The queries without intervals and the intervals which can't be parsed count as touching now.
+func touchesNow(query Query, now time.Time) bool {
+	intervals, ok := queryIntervals(query)
+	if !ok || len(intervals) == 0 {
+		return true
+	}
+	for _, iv := range intervals {
+		interval, err := ParseInterval(iv)
+		if err != nil || interval.End.After(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// ---------------------------------
+// Per query cache mode
+// ---------------------------------
+
+type CacheMode int
+
+const (
+	// CacheDefault reads from and writes to the cache.
+	CacheDefault CacheMode = iota
+	// CacheBypass neither reads from nor writes to the cache.
+	CacheBypass
+	// CacheRefresh skips reading the cache, but stores the fresh result.
+	CacheRefresh
+)
+
+type cacheModeKey struct{}
+
+// WithCacheMode returns a copy of ctx which makes the client cache behave as mode for the queries run with it.
+func WithCacheMode(ctx context.Context, mode CacheMode) context.Context {
+	return context.WithValue(ctx, cacheModeKey{}, mode)
+}
+
+func cacheModeFrom(ctx context.Context) CacheMode {
+	mode, _ := ctx.Value(cacheModeKey{}).(CacheMode)
+	return mode
+}
+
+// ---------------------------------
+// LRU Cache
+// ---------------------------------
+
+// LRUCache is an in-memory Cache which evicts the least recently used entries
+// once it holds more than MaxEntries entries or MaxBytes bytes of values.
+type LRUCache struct {
+	MaxEntries int   // 0 means no limit.
+	MaxBytes   int64 // 0 means no limit.
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+	bytes int64
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+func NewLRUCache(maxEntries int, maxBytes int64) *LRUCache {
+	return &LRUCache{
+		MaxEntries: maxEntries,
+		MaxBytes:   maxBytes,
+	}
+}
+
+func (c *LRUCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*lruEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.remove(el)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return entry.value, true
+}
+
+func (c *LRUCache) Set(key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.items == nil {
+		c.ll = list.New()
+		c.items = map[string]*list.Element{}
+	}
+	if c.MaxBytes > 0 && int64(len(value)) > c.MaxBytes {
+		return
+	}
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	entry := &lruEntry{key: key, value: value}
+	if ttl > 0 {
+		entry.expires = time.Now().Add(ttl)
+	}
+	c.items[key] = c.ll.PushFront(entry)
+	c.bytes += int64(len(value))
+
+	for (c.MaxEntries > 0 && c.ll.Len() > c.MaxEntries) || (c.MaxBytes > 0 && c.bytes > c.MaxBytes) {
+		c.remove(c.ll.Back())
+	}
+}
+
+// Len returns the number of entries in the cache.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ll == nil {
+		return 0
+	}
+	return c.ll.Len()
+}
+
+func (c *LRUCache) remove(el *list.Element) {
+	entry := c.ll.Remove(el).(*lruEntry)
+	delete(c.items, entry.key)
+	c.bytes -= int64(len(entry.value))
+}
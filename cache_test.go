@@ -0,0 +1,67 @@
+package godruid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLRUCache(t *testing.T) {
+	Convey("TestLRUCache", t, func() {
+		cache := NewLRUCache(2, 10)
+		cache.Set("a", []byte("1"), 0)
+		cache.Set("b", []byte("2"), 0)
+		cache.Get("a")
+		cache.Set("c", []byte("3"), 0)
+		_, ok := cache.Get("b")
+		So(ok, ShouldBeFalse)
+		v, ok := cache.Get("a")
+		So(ok, ShouldBeTrue)
+		So(string(v), ShouldEqual, "1")
+
+		cache.Set("d", []byte("1234567890"), 0)
+		So(cache.Len(), ShouldEqual, 1)
+
+		cache.Set("e", []byte("5"), time.Nanosecond)
+		time.Sleep(time.Millisecond)
+		_, ok = cache.Get("e")
+		So(ok, ShouldBeFalse)
+	})
+}
+
+func TestClientCache(t *testing.T) {
+	Convey("TestClientCache", t, func() {
+		var hits int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&hits, 1)
+			w.Write([]byte(`[{"timestamp":"2016-05-01T00:00:00.000Z","result":{"count":1}}]`))
+		}))
+		defer server.Close()
+
+		client := Client{Url: server.URL, Cache: NewLRUCache(100, 0)}
+		historical := func() *QueryTimeseries {
+			return &QueryTimeseries{DataSource: "ds", Granularity: GranAll, Intervals: []string{"2016-05-01T00:00/2016-05-01T01"}}
+		}
+
+		q := historical()
+		So(client.Query(q), ShouldBeNil)
+		So(client.Query(historical()), ShouldBeNil)
+		So(atomic.LoadInt32(&hits), ShouldEqual, int32(1))
+
+		So(client.QueryContext(WithCacheMode(context.Background(), CacheRefresh), historical()), ShouldBeNil)
+		So(atomic.LoadInt32(&hits), ShouldEqual, int32(2))
+		So(client.QueryContext(WithCacheMode(context.Background(), CacheBypass), historical()), ShouldBeNil)
+		So(atomic.LoadInt32(&hits), ShouldEqual, int32(3))
+
+		recent := &QueryTimeseries{DataSource: "ds", Granularity: GranAll, Intervals: []string{"2016-05-01/2999-01-01"}}
+		So(client.Query(recent), ShouldBeNil)
+		So(client.Query(recent), ShouldBeNil)
+		So(atomic.LoadInt32(&hits), ShouldEqual, int32(5))
+		So(recent.QueryResult, ShouldHaveLength, 1)
+	})
+}
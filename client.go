@@ -2,10 +2,12 @@ package godruid
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sync"
 )
 
 const (
@@ -16,12 +18,31 @@ type Client struct {
 	Url      string
 	EndPoint string
 
+	// HttpClient is used to send the requests, http.DefaultClient if nil.
+	HttpClient *http.Client
+
+	// Dedup makes the concurrent identical queries (see QueryKey) share one round-trip.
+	Dedup bool
+
+	// Cache stores the query results if not nil, CachePolicy decides for how long.
+	// Use WithCacheMode to bypass or refresh the cache for a query.
+	Cache       Cache
+	CachePolicy CachePolicy
+
 	Debug        bool
 	LastRequest  string
 	LastResponse string
+
+	mu      sync.Mutex
+	flights flightGroup
 }
 
 func (c *Client) Query(query Query) (err error) {
+	return c.QueryContext(context.Background(), query)
+}
+
+// QueryContext is like Query, but the request is bound to ctx.
+func (c *Client) QueryContext(ctx context.Context, query Query) (err error) {
 	query.setup()
 	var reqJson []byte
 	if c.Debug {
@@ -32,28 +53,69 @@ func (c *Client) Query(query Query) (err error) {
 	if err != nil {
 		return
 	}
-	result, err := c.QueryRaw(reqJson)
+	var key string
+	if c.Dedup || c.Cache != nil {
+		if key, err = QueryKey(query); err != nil {
+			return
+		}
+	}
+	mode := cacheModeFrom(ctx)
+	if c.Cache != nil && mode == CacheDefault {
+		if result, ok := c.Cache.Get(key); ok {
+			return query.onResponse(result)
+		}
+	}
+
+	var result []byte
+	if c.Dedup {
+		result, err = c.flights.do(ctx, key, func(ctx context.Context) ([]byte, error) {
+			return c.QueryRawContext(ctx, reqJson)
+		})
+	} else {
+		result, err = c.QueryRawContext(ctx, reqJson)
+	}
 	if err != nil {
 		return
 	}
 
+	if c.Cache != nil && mode != CacheBypass {
+		if ttl, ok := c.CachePolicy.ttl(query, result, now()); ok {
+			c.Cache.Set(key, result, ttl)
+		}
+	}
+
 	return query.onResponse(result)
 }
 
 func (c *Client) QueryRaw(req []byte) (result []byte, err error) {
-	if c.EndPoint == "" {
-		c.EndPoint = DefaultEndPoint
-	}
+	return c.QueryRawContext(context.Background(), req)
+}
+
+// QueryRawContext is like QueryRaw, but the request is bound to ctx.
+func (c *Client) QueryRawContext(ctx context.Context, req []byte) (result []byte, err error) {
 	endPoint := c.EndPoint
+	if endPoint == "" {
+		endPoint = DefaultEndPoint
+	}
 	if c.Debug {
 		endPoint += "?pretty"
+		c.mu.Lock()
 		c.LastRequest = string(req)
+		c.mu.Unlock()
 	}
+
+	httpReq, err := http.NewRequest("POST", c.Url+endPoint, bytes.NewBuffer(req))
 	if err != nil {
 		return
 	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq = httpReq.WithContext(ctx)
 
-	resp, err := http.Post(c.Url+endPoint, "application/json", bytes.NewBuffer(req))
+	httpClient := c.HttpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(httpReq)
 	if err != nil {
 		return
 	}
@@ -66,7 +128,9 @@ func (c *Client) QueryRaw(req []byte) (result []byte, err error) {
 		return
 	}
 	if c.Debug {
+		c.mu.Lock()
 		c.LastResponse = string(result)
+		c.mu.Unlock()
 	}
 
 	if resp.StatusCode != http.StatusOK {
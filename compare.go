@@ -0,0 +1,187 @@
+package godruid
+
+import (
+	"context"
+	"fmt"
+)
+
+// CompareValue holds a metric of a bucket in the current and the previous period.
+// The missing values are nil, DeltaPct is also nil when the previous value is 0.
+type CompareValue struct {
+	Current  *float64 `json:"current"`
+	Previous *float64 `json:"previous"`
+	Delta    *float64 `json:"delta"`
+	DeltaPct *float64 `json:"deltaPct"`
+}
+
+// CompareRow is a bucket of the current period joined with the same bucket of the previous period.
+type CompareRow struct {
+	Timestamp         string                  `json:"timestamp"`
+	PreviousTimestamp string                  `json:"previousTimestamp"`
+	Dimensions        map[string]interface{}  `json:"dimensions,omitempty"`
+	Metrics           map[string]CompareValue `json:"metrics"`
+}
+
+// QueryCompare runs a timeseries, topN or groupBy query together with its copy shifted back by the
+// ISO-8601 period, like "P1W" for week-over-week, and joins the results on the bucket and the dimension values.
+// Every aggregation and post aggregation gets its current and previous values with their deltas.
+// The rows only found in the previous period come after the others.
+// The query gets the current results, the options are passed to QueryBatch.
+func (c *Client) QueryCompare(ctx context.Context, query Query, period string, options ...BatchOption) ([]CompareRow, error) {
+	p, err := ParsePeriod(period)
+	if err != nil {
+		return nil, err
+	}
+	intervals, _ := queryIntervals(query)
+	ivs, err := ParseIntervals(intervals)
+	if err != nil {
+		return nil, err
+	}
+	for i := range ivs {
+		ivs[i] = IntervalOf(p.AddTo(ivs[i].Start, -1), p.AddTo(ivs[i].End, -1))
+	}
+
+	var (
+		aggs     []Aggregation
+		postAggs []PostAggregation
+	)
+	switch q := query.(type) {
+	case *QueryTimeseries:
+		aggs, postAggs = q.Aggregations, q.PostAggregations
+	case *QueryTopN:
+		aggs, postAggs = q.Aggregations, q.PostAggregations
+	case *QueryGroupBy:
+		aggs, postAggs = q.Aggregations, q.PostAggregations
+	default:
+		return nil, fmt.Errorf("can't compare query of type %T", query)
+	}
+	previous, _ := withIntervals(query, Intervals(ivs...))
+	if _, err := c.QueryBatch(ctx, []Query{query, previous}, append(options, FailFast(true))...); err != nil {
+		return nil, err
+	}
+
+	var metrics []string
+	for _, agg := range aggs {
+		metrics = append(metrics, agg.outputName())
+	}
+	for _, pa := range postAggs {
+		metrics = append(metrics, pa.Name)
+	}
+	cur := compareRows(query, aggs, postAggs)
+	prev := compareRows(previous, aggs, postAggs)
+
+	// The current buckets are shifted back rather than the previous ones forward, as adding months clamps
+	// the day: 2016-03-30 and 2016-03-31 both go back to 2016-02-29, which only goes forward to 2016-03-29.
+	prevIndex := map[string]int{}
+	for i, r := range prev {
+		t, err := parseIntervalTime(r.timestamp)
+		if err != nil {
+			return nil, err
+		}
+		prevIndex[fmt.Sprint(t.UnixNano(), r.key)] = i
+	}
+	matched := make([]bool, len(prev))
+	var res []CompareRow
+	for ci, r := range cur {
+		t, err := parseIntervalTime(r.timestamp)
+		if err != nil {
+			return nil, err
+		}
+		var pr *compareRow
+		if i, ok := prevIndex[fmt.Sprint(p.AddTo(t, -1).UnixNano(), r.key)]; ok {
+			pr, matched[i] = &prev[i], true
+		}
+		res = append(res, joinCompareRows(&cur[ci], pr, metrics, p))
+	}
+	for i := range prev {
+		if !matched[i] {
+			res = append(res, joinCompareRows(nil, &prev[i], metrics, p))
+		}
+	}
+	return res, nil
+}
+
+// compareRow is a result row flattened for the join.
+type compareRow struct {
+	timestamp string
+	key       string
+	dims      map[string]interface{}
+	values    map[string]interface{}
+}
+
+func compareRows(query Query, aggs []Aggregation, postAggs []PostAggregation) []compareRow {
+	var rows []compareRow
+	add := func(timestamp string, row map[string]interface{}) {
+		dims := copyRow(row)
+		for _, agg := range aggs {
+			delete(dims, agg.outputName())
+		}
+		for _, pa := range postAggs {
+			delete(dims, pa.Name)
+		}
+		rows = append(rows, compareRow{
+			timestamp: timestamp,
+			key:       dimensionKey(row, aggs, postAggs),
+			dims:      dims,
+			values:    row,
+		})
+	}
+	switch q := query.(type) {
+	case *QueryTimeseries:
+		for _, r := range q.QueryResult {
+			add(r.Timestamp, r.Result)
+		}
+	case *QueryTopN:
+		for _, item := range q.QueryResult {
+			for _, r := range item.Result {
+				add(item.Timestamp, r)
+			}
+		}
+	case *QueryGroupBy:
+		for _, r := range q.QueryResult {
+			add(r.Timestamp, r.Event)
+		}
+	}
+	return rows
+}
+
+func joinCompareRows(cur, prev *compareRow, metrics []string, p Period) CompareRow {
+	var res CompareRow
+	var curValues, prevValues map[string]interface{}
+	if cur != nil {
+		res.Timestamp, res.Dimensions, curValues = cur.timestamp, cur.dims, cur.values
+	}
+	if prev != nil {
+		res.PreviousTimestamp, prevValues = prev.timestamp, prev.values
+		if cur == nil {
+			res.Dimensions = prev.dims
+			if t, err := parseIntervalTime(prev.timestamp); err == nil {
+				res.Timestamp = p.AddTo(t, 1).Format(IntervalTimeFormat)
+			}
+		}
+	}
+	if len(res.Dimensions) == 0 {
+		res.Dimensions = nil
+	}
+
+	res.Metrics = make(map[string]CompareValue, len(metrics))
+	for _, m := range metrics {
+		var v CompareValue
+		if f, ok := toFloat(curValues[m]); ok {
+			v.Current = &f
+		}
+		if f, ok := toFloat(prevValues[m]); ok {
+			v.Previous = &f
+		}
+		if v.Current != nil && v.Previous != nil {
+			delta := *v.Current - *v.Previous
+			v.Delta = &delta
+			if *v.Previous != 0 {
+				pct := delta / *v.Previous * 100
+				v.DeltaPct = &pct
+			}
+		}
+		res.Metrics[m] = v
+	}
+	return res
+}
@@ -0,0 +1,122 @@
+package godruid
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestQueryCompare(t *testing.T) {
+	Convey("TestQueryCompare", t, func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			var q map[string]interface{}
+			json.Unmarshal(body, &q)
+			if strings.HasPrefix(q["intervals"].([]interface{})[0].(string), "2016-05-08") {
+				w.Write([]byte(`[{"version":"v1","timestamp":"2016-05-08T00:00:00.000Z","event":{"os":"ios","count":15}},
+					{"version":"v1","timestamp":"2016-05-08T00:00:00.000Z","event":{"os":"web","count":1}}]`))
+				return
+			}
+			w.Write([]byte(`[{"version":"v1","timestamp":"2016-05-01T00:00:00.000Z","event":{"os":"ios","count":10}},
+				{"version":"v1","timestamp":"2016-05-01T00:00:00.000Z","event":{"os":"android","count":4}}]`))
+		}))
+		defer server.Close()
+		client := Client{Url: server.URL}
+
+		query := &QueryGroupBy{
+			DataSource:   "ds",
+			Intervals:    []string{"2016-05-08/2016-05-09"},
+			Granularity:  GranDay,
+			Dimensions:   []DimSpec{"os"},
+			Aggregations: []Aggregation{AggCount("count")},
+		}
+		rows, err := client.QueryCompare(context.Background(), query, "P1W")
+		So(err, ShouldBeNil)
+		So(rows, ShouldHaveLength, 3)
+		So(query.QueryResult, ShouldHaveLength, 2)
+
+		ios := rows[0]
+		So(ios.Dimensions, ShouldResemble, map[string]interface{}{"os": "ios"})
+		So(ios.PreviousTimestamp, ShouldEqual, "2016-05-01T00:00:00.000Z")
+		So(*ios.Metrics["count"].Delta, ShouldEqual, 5.0)
+		So(*ios.Metrics["count"].DeltaPct, ShouldEqual, 50.0)
+
+		So(rows[1].Metrics["count"].Previous, ShouldBeNil)
+		android := rows[2]
+		So(android.Timestamp, ShouldEqual, "2016-05-08T00:00:00.000Z")
+		So(android.Metrics["count"].Current, ShouldBeNil)
+		So(*android.Metrics["count"].Previous, ShouldEqual, 4.0)
+	})
+}
+
+func TestQueryCompareMonthEnd(t *testing.T) {
+	Convey("TestQueryCompareMonthEnd", t, func() {
+		var (
+			mu        sync.Mutex
+			intervals []string
+		)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			var q map[string]interface{}
+			json.Unmarshal(body, &q)
+			iv := q["intervals"].([]interface{})[0].(string)
+			mu.Lock()
+			intervals = append(intervals, iv)
+			mu.Unlock()
+			switch {
+			case strings.HasPrefix(iv, "2016-03-31"):
+				w.Write([]byte(`[{"timestamp":"2016-03-31T00:00:00.000Z","result":{"count":6}}]`))
+			case strings.HasPrefix(iv, "2016-02-29"):
+				w.Write([]byte(`[{"timestamp":"2016-02-29T00:00:00.000Z","result":{"count":3}}]`))
+			case strings.HasPrefix(iv, "2016-02-28"):
+				w.Write([]byte(`[{"timestamp":"2016-02-28T00:00:00.000Z","result":{"count":4}},
+					{"timestamp":"2016-02-29T00:00:00.000Z","result":{"count":5}}]`))
+			case strings.HasPrefix(iv, "2015-02-28"):
+				w.Write([]byte(`[{"timestamp":"2015-02-28T00:00:00.000Z","result":{"count":2}}]`))
+			default:
+				w.Write([]byte(`[]`))
+			}
+		}))
+		defer server.Close()
+		client := Client{Url: server.URL}
+
+		Convey("month over month on the 31st", func() {
+			query := &QueryTimeseries{
+				DataSource:   "ds",
+				Intervals:    []string{"2016-03-31/2016-04-01"},
+				Granularity:  GranDay,
+				Aggregations: []Aggregation{AggCount("count")},
+			}
+			rows, err := client.QueryCompare(context.Background(), query, "P1M")
+			So(err, ShouldBeNil)
+			So(intervals, ShouldContain, "2016-02-29T00:00:00.000Z/2016-03-01T00:00:00.000Z")
+			So(rows, ShouldHaveLength, 1)
+			So(rows[0].PreviousTimestamp, ShouldEqual, "2016-02-29T00:00:00.000Z")
+			So(*rows[0].Metrics["count"].Delta, ShouldEqual, 3.0)
+		})
+
+		Convey("year over year on Feb 29", func() {
+			query := &QueryTimeseries{
+				DataSource:   "ds",
+				Intervals:    []string{"2016-02-28/2016-03-01"},
+				Granularity:  GranDay,
+				Aggregations: []Aggregation{AggCount("count")},
+			}
+			rows, err := client.QueryCompare(context.Background(), query, "P1Y")
+			So(err, ShouldBeNil)
+			So(intervals, ShouldContain, "2015-02-28T00:00:00.000Z/2015-03-01T00:00:00.000Z")
+			So(rows, ShouldHaveLength, 2)
+			So(rows[0].PreviousTimestamp, ShouldEqual, "2015-02-28T00:00:00.000Z")
+			So(rows[1].Timestamp, ShouldEqual, "2016-02-29T00:00:00.000Z")
+			So(rows[1].PreviousTimestamp, ShouldEqual, "2015-02-28T00:00:00.000Z")
+			So(*rows[1].Metrics["count"].Previous, ShouldEqual, 2.0)
+		})
+	})
+}
@@ -0,0 +1,118 @@
+package godruid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// VolatileContextKeys are the query context keys which don't affect the query result,
+// they are left out of the QueryKey.
+var VolatileContextKeys = []string{"queryId", "sqlQueryId", "subQueryId"}
+
+// QueryKey returns the canonical serialized json of the query, with VolatileContextKeys excluded.
+// Two queries asking for the same results have the same key.
+func QueryKey(query Query) (string, error) {
+	query.setup()
+	raw, err := json.Marshal(query)
+	if err != nil {
+		return "", err
+	}
+	// Go through a generic map so that the keys get sorted, the numbers are kept as is
+	// since the large integers can't be told apart once rounded to float64.
+	m := map[string]interface{}{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&m); err != nil {
+		return "", err
+	}
+	if ctx, ok := m["context"].(map[string]interface{}); ok {
+		for _, k := range VolatileContextKeys {
+			delete(ctx, k)
+		}
+		if len(ctx) == 0 {
+			delete(m, "context")
+		}
+	}
+	key, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	return string(key), nil
+}
+
+// ---------------------------------
+// Single flight
+// ---------------------------------
+
+type flightCall struct {
+	done    chan struct{}
+	result  []byte
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// flightGroup shares one round-trip between the identical in-flight requests.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// do runs fn for the first caller of key, the concurrent callers of the same key wait and share its result.
+// A waiting caller, the first one included, gives up when its own ctx is done. fn runs under a context
+// detached from the callers', which is cancelled once all of them have given up.
+func (g *flightGroup) do(ctx context.Context, key string, fn func(context.Context) ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*flightCall{}
+	}
+	call, ok := g.calls[key]
+	if !ok {
+		callCtx, cancel := context.WithCancel(detachedContext{ctx})
+		call = &flightCall{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = call
+		go func() {
+			result, err := fn(callCtx)
+			g.mu.Lock()
+			call.result, call.err = result, err
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+			g.mu.Unlock()
+			close(call.done)
+			cancel()
+		}()
+	}
+	call.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.result, call.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			// Nobody is left for the result, the next callers start a new call.
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+			call.cancel()
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// detachedContext keeps the values of its parent, but neither its deadline nor its cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
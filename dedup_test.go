@@ -0,0 +1,98 @@
+package godruid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestQueryKey(t *testing.T) {
+	Convey("TestQueryKey", t, func() {
+		q1 := &QueryTimeseries{DataSource: "ds", Granularity: GranAll, Context: map[string]interface{}{"queryId": "a"}}
+		q2 := &QueryTimeseries{DataSource: "ds", Granularity: GranAll, Context: map[string]interface{}{"queryId": "b"}}
+		q3 := &QueryTimeseries{DataSource: "ds", Granularity: GranAll, Context: map[string]interface{}{"useCache": false}}
+		k1, err := QueryKey(q1)
+		So(err, ShouldBeNil)
+		k2, _ := QueryKey(q2)
+		k3, _ := QueryKey(q3)
+		So(k1, ShouldEqual, k2)
+		So(k1, ShouldNotEqual, k3)
+
+		// 2^53 + 1 and 2^53 are the same float64.
+		q4 := &QueryTimeseries{DataSource: "ds", Granularity: GranAll, Context: map[string]interface{}{"minTopNThreshold": int64(9007199254740993)}}
+		q5 := &QueryTimeseries{DataSource: "ds", Granularity: GranAll, Context: map[string]interface{}{"minTopNThreshold": int64(9007199254740992)}}
+		k4, err := QueryKey(q4)
+		So(err, ShouldBeNil)
+		k5, _ := QueryKey(q5)
+		So(k4, ShouldNotEqual, k5)
+		So(k4, ShouldContainSubstring, "9007199254740993")
+	})
+}
+
+func TestDedup(t *testing.T) {
+	Convey("TestDedup", t, func() {
+		var hits int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&hits, 1)
+			time.Sleep(100 * time.Millisecond)
+			w.Write([]byte(`[{"timestamp":"2016-05-01T00:00:00.000Z","result":{"count":1}}]`))
+		}))
+		defer server.Close()
+
+		client := Client{Url: server.URL, Dedup: true}
+		queries := make([]*QueryTimeseries, 5)
+		var wg sync.WaitGroup
+		for i := range queries {
+			queries[i] = &QueryTimeseries{DataSource: "ds", Granularity: GranAll}
+			wg.Add(1)
+			go func(q *QueryTimeseries) {
+				defer wg.Done()
+				client.Query(q)
+			}(queries[i])
+		}
+		wg.Wait()
+
+		So(atomic.LoadInt32(&hits), ShouldEqual, int32(1))
+		for _, q := range queries {
+			So(q.QueryResult, ShouldHaveLength, 1)
+		}
+		queries[0].QueryResult[0].Result["count"] = 2
+		So(queries[1].QueryResult[0].Result["count"], ShouldEqual, float64(1))
+	})
+}
+
+func TestDedupLeaderCancel(t *testing.T) {
+	Convey("TestDedupLeaderCancel", t, func() {
+		var hits int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&hits, 1)
+			time.Sleep(200 * time.Millisecond)
+			w.Write([]byte(`[{"timestamp":"2016-05-01T00:00:00.000Z","result":{"count":1}}]`))
+		}))
+		defer server.Close()
+		client := Client{Url: server.URL, Dedup: true}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		leader := &QueryTimeseries{DataSource: "ds", Granularity: GranAll}
+		leaderErr := make(chan error, 1)
+		go func() { leaderErr <- client.QueryContext(ctx, leader) }()
+		time.Sleep(50 * time.Millisecond)
+
+		follower := &QueryTimeseries{DataSource: "ds", Granularity: GranAll}
+		followerErr := make(chan error, 1)
+		go func() { followerErr <- client.Query(follower) }()
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+
+		So(<-leaderErr, ShouldEqual, context.Canceled)
+		So(<-followerErr, ShouldBeNil)
+		So(follower.QueryResult, ShouldHaveLength, 1)
+		So(atomic.LoadInt32(&hits), ShouldEqual, int32(1))
+	})
+}
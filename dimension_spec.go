@@ -156,7 +156,7 @@ func DimExFnSearchQuerySpec(query *SearchQuery) *DimExtractionFn {
 
 func DimExFnSubstringQuerySpec(index int, options ...DimExFnOption) *DimExtractionFn {
 	exFn := &DimExtractionFn{
-		Type:  "searchQuery",
+		Type:  "substring",
 		Index: index,
 	}
 	for _, opt := range options {
@@ -257,3 +257,23 @@ func DimExFnLower(options ...DimExFnOption) *DimExtractionFn {
 	}
 	return exFn
 }
+
+// ---------------------------------
+// Helpers
+// ---------------------------------
+
+// dimOutputName returns the name under which the dimension shows up in the results.
+func dimOutputName(dim DimSpec) string {
+	switch d := dim.(type) {
+	case string:
+		return d
+	case *Dimension:
+		if d.OutputName != "" {
+			return d.OutputName
+		}
+		return d.Dimension
+	case Dimension:
+		return dimOutputName(&d)
+	}
+	return ""
+}
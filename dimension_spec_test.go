@@ -0,0 +1,16 @@
+package godruid
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDimExtractionFn(t *testing.T) {
+	Convey("TestDimExtractionFn", t, func() {
+		b, err := json.Marshal(DimExFnSubstringQuerySpec(1, Length(3)))
+		So(err, ShouldBeNil)
+		So(string(b), ShouldEqual, `{"type":"substring","index":1,"length":3}`)
+	})
+}
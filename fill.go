@@ -0,0 +1,203 @@
+package godruid
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ---------------------------------
+// Options
+// ---------------------------------
+
+// DefaultFillMaxBuckets is the number of buckets the fillers give up beyond when no FillMaxBuckets option is given.
+const DefaultFillMaxBuckets = 100000
+
+type fillConfig struct {
+	values     map[string]interface{}
+	nulls      bool
+	maxBuckets int
+}
+
+type FillOption interface {
+	apply(*fillConfig)
+}
+
+// FillValues sets the values of the missing metrics by name, the others default to 0.
+type FillValues map[string]interface{}
+
+func (m FillValues) apply(c *fillConfig) {
+	for k, v := range m {
+		c.values[k] = v
+	}
+}
+
+// FillNulls makes the missing metrics default to nil instead of 0.
+type FillNulls bool
+
+func (b FillNulls) apply(c *fillConfig) { c.nulls = bool(b) }
+
+// FillMaxBuckets bounds the number of buckets to fill, the fillers fail beyond.
+type FillMaxBuckets int
+
+func (i FillMaxBuckets) apply(c *fillConfig) { c.maxBuckets = int(i) }
+
+// ---------------------------------
+// Fillers
+// ---------------------------------
+
+// FillTimeseries returns the results of an executed timeseries query with a row for every bucket
+// of the query granularity between its intervals, the missing rows get the default metric values.
+// The granularities all and none, which have no bucket to fill, are rejected.
+func FillTimeseries(q *QueryTimeseries, options ...FillOption) ([]Timeseries, error) {
+	conf := newFillConfig(options)
+	buckets, err := fillBuckets(q.Granularity, q.Intervals, conf.maxBuckets)
+	if err != nil {
+		return nil, err
+	}
+	defaults := fillDefaults(q.Aggregations, q.PostAggregations, conf)
+
+	rows := map[int64]Timeseries{}
+	for _, r := range q.QueryResult {
+		t, err := parseIntervalTime(r.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		rows[t.UnixNano()] = r
+	}
+	res := make([]Timeseries, 0, len(buckets))
+	for _, b := range buckets {
+		if r, ok := rows[b.UnixNano()]; ok {
+			res = append(res, r)
+			continue
+		}
+		res = append(res, Timeseries{Timestamp: b.Format(IntervalTimeFormat), Result: copyRow(defaults)})
+	}
+	if q.Descending {
+		for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+			res[i], res[j] = res[j], res[i]
+		}
+	}
+	return res, nil
+}
+
+// FillGroupBy returns the results of an executed groupBy query with a row for every bucket
+// of the query granularity between its intervals and every combination of dimension values found
+// in the results. The missing rows get the default metric values.
+// The rows filtered out by having or limitSpec are filled too.
+// The granularities all and none, which have no bucket to fill, are rejected.
+func FillGroupBy(q *QueryGroupBy, options ...FillOption) ([]GroupbyItem, error) {
+	conf := newFillConfig(options)
+	buckets, err := fillBuckets(q.Granularity, q.Intervals, conf.maxBuckets)
+	if err != nil {
+		return nil, err
+	}
+	defaults := fillDefaults(q.Aggregations, q.PostAggregations, conf)
+
+	var (
+		combos  []string
+		dims    = map[string]map[string]interface{}{}
+		rows    = map[int64]map[string]GroupbyItem{}
+		version = "v1"
+	)
+	for _, r := range q.QueryResult {
+		t, err := parseIntervalTime(r.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		key := dimensionKey(r.Event, q.Aggregations, q.PostAggregations)
+		if _, ok := dims[key]; !ok {
+			combos = append(combos, key)
+			dim := copyRow(r.Event)
+			for k := range defaults {
+				delete(dim, k)
+			}
+			dims[key] = dim
+		}
+		if rows[t.UnixNano()] == nil {
+			rows[t.UnixNano()] = map[string]GroupbyItem{}
+		}
+		rows[t.UnixNano()][key] = r
+		version = r.Version
+	}
+
+	res := make([]GroupbyItem, 0, len(buckets)*len(combos))
+	for _, b := range buckets {
+		for _, key := range combos {
+			if r, ok := rows[b.UnixNano()][key]; ok {
+				res = append(res, r)
+				continue
+			}
+			event := copyRow(dims[key])
+			for k, v := range defaults {
+				event[k] = v
+			}
+			res = append(res, GroupbyItem{Version: version, Timestamp: b.Format(IntervalTimeFormat), Event: event})
+		}
+	}
+	return res, nil
+}
+
+func newFillConfig(options []FillOption) fillConfig {
+	conf := fillConfig{values: map[string]interface{}{}, maxBuckets: DefaultFillMaxBuckets}
+	for _, opt := range options {
+		opt.apply(&conf)
+	}
+	return conf
+}
+
+// fillBuckets returns the ascending starts of the buckets of the intervals, at most maxBuckets of them.
+func fillBuckets(gran Granularity, intervals []string, maxBuckets int) ([]time.Time, error) {
+	g, err := NormalizeGran(gran)
+	if err != nil {
+		return nil, err
+	}
+	if g.IsAll() || g.IsNone() {
+		return nil, fmt.Errorf("can't fill granularity %s", g)
+	}
+	ivs, err := ParseIntervals(intervals)
+	if err != nil {
+		return nil, err
+	}
+	if len(ivs) == 0 {
+		return nil, fmt.Errorf("query without intervals")
+	}
+	ivs = UnionIntervals(ivs...)
+	n := 0
+	for _, iv := range ivs {
+		if n += g.CountBuckets(iv); n > maxBuckets {
+			return nil, fmt.Errorf("more than %d %s buckets to fill", maxBuckets, g)
+		}
+	}
+
+	seen := map[int64]bool{}
+	var starts []time.Time
+	for _, iv := range ivs {
+		for _, b := range g.Buckets(iv) {
+			if !seen[b.Start.UnixNano()] {
+				seen[b.Start.UnixNano()] = true
+				starts = append(starts, b.Start)
+			}
+		}
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	return starts, nil
+}
+
+func fillDefaults(aggs []Aggregation, postAggs []PostAggregation, conf fillConfig) map[string]interface{} {
+	var zero interface{} = 0.0
+	if conf.nulls {
+		zero = nil
+	}
+	defaults := map[string]interface{}{}
+	for _, agg := range aggs {
+		defaults[agg.outputName()] = zero
+	}
+	for _, pa := range postAggs {
+		defaults[pa.Name] = zero
+	}
+	for k, v := range conf.values {
+		defaults[k] = v
+	}
+	return defaults
+}
@@ -0,0 +1,72 @@
+package godruid
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFill(t *testing.T) {
+	Convey("TestFill", t, func() {
+		Convey("timeseries", func() {
+			query := &QueryTimeseries{
+				Intervals:    []string{"2016-05-01T00:00/2016-05-01T04:00"},
+				Granularity:  GranHour,
+				Aggregations: []Aggregation{AggCount("count"), AggLongSum("revenue", "revenue")},
+				QueryResult: []Timeseries{
+					{Timestamp: "2016-05-01T01:00:00.000Z", Result: map[string]interface{}{"count": 3.0, "revenue": 7.0}},
+				},
+			}
+			res, err := FillTimeseries(query, FillValues{"revenue": -1})
+			So(err, ShouldBeNil)
+			So(res, ShouldHaveLength, 4)
+			So(res[0].Timestamp, ShouldEqual, "2016-05-01T00:00:00.000Z")
+			So(res[0].Result, ShouldResemble, map[string]interface{}{"count": 0.0, "revenue": -1})
+			So(res[1].Result["count"], ShouldEqual, 3.0)
+
+			res, _ = FillTimeseries(query, FillNulls(true))
+			So(res[3].Result["count"], ShouldBeNil)
+		})
+
+		Convey("limits", func() {
+			query := &QueryTimeseries{Intervals: []string{"2016-01-01/2017-01-01"}, Granularity: GranNone}
+			_, err := FillTimeseries(query)
+			So(err, ShouldNotBeNil)
+			query.Granularity = GranAll
+			_, err = FillTimeseries(query)
+			So(err, ShouldNotBeNil)
+
+			query.Granularity = GranSecond
+			_, err = FillTimeseries(query)
+			So(err, ShouldNotBeNil)
+			query.Granularity = GranDay
+			res, err := FillTimeseries(query)
+			So(err, ShouldBeNil)
+			So(res, ShouldHaveLength, 366)
+			_, err = FillTimeseries(query, FillMaxBuckets(365))
+			So(err, ShouldNotBeNil)
+
+			_, err = FillGroupBy(&QueryGroupBy{Intervals: []string{"2016-01-01/2017-01-01"}, Granularity: GranMinute})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("groupBy", func() {
+			query := &QueryGroupBy{
+				Intervals:    []string{"2016-05-01/2016-05-03"},
+				Granularity:  GranDay,
+				Aggregations: []Aggregation{AggCount("count")},
+				QueryResult: []GroupbyItem{
+					{Version: "v1", Timestamp: "2016-05-01T00:00:00.000Z", Event: map[string]interface{}{"os": "ios", "count": 1.0}},
+					{Version: "v1", Timestamp: "2016-05-02T00:00:00.000Z", Event: map[string]interface{}{"os": "android", "count": 2.0}},
+				},
+			}
+			res, err := FillGroupBy(query)
+			So(err, ShouldBeNil)
+			So(res, ShouldHaveLength, 4)
+			So(res[1].Timestamp, ShouldEqual, "2016-05-01T00:00:00.000Z")
+			So(res[1].Event, ShouldResemble, map[string]interface{}{"os": "android", "count": 0.0})
+			So(res[2].Event, ShouldResemble, map[string]interface{}{"os": "ios", "count": 0.0})
+			So(res[3].Event["count"], ShouldEqual, 2.0)
+		})
+	})
+}
@@ -0,0 +1,616 @@
+package godruid
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The filter DSL is a compact SQL-like boolean expression language:
+//
+//	country = 'US' AND (device IN ('ios','android') OR NOT browser ~ '^Chrome')
+//
+// The comparisons are
+//
+//	dim = 'v'                  selector
+//	dim != 'v', dim <> 'v'     not selector
+//	dim IS NULL                selector on null, IS NOT NULL negates it
+//	dim IN ('a', 'b')          in, NOT IN negates it
+//	dim ~ 'regex'              regex
+//	dim < 'v', <=, >, >=       bound, numeric literals make a numeric bound
+//	dim BETWEEN 'a' AND 'b'    bound including both ends
+//
+// and they are combined with NOT, AND and OR, in decreasing precedence, and the parentheses.
+// The keywords are case insensitive. The strings are single-quoted, a quote is doubled to escape it,
+// the dimension names could be double-quoted when they are not plain identifiers.
+// The numbers are left unquoted, a selector on a number keeps it as a json.Number.
+// The filters with an extraction function can't be written in the DSL.
+
+// FilterSyntaxError reports where a filter expression is invalid.
+type FilterSyntaxError struct {
+	Expr string
+	Pos  int // Byte offset in Expr.
+	Msg  string
+}
+
+func (e *FilterSyntaxError) Error() string {
+	return fmt.Sprintf("filter syntax error at %d: %s", e.Pos, e.Msg)
+}
+
+// ParseFilter parses a filter expression of the filter DSL.
+func ParseFilter(expr string) (*Filter, error) {
+	p := &filterParser{lex: filterLexer{src: expr}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+	return f, nil
+}
+
+// FormatFilter prints a filter in the filter DSL, ParseFilter reads it back.
+// Only the selector, in, regex, bound, and, or and not filters could be printed.
+func FormatFilter(f *Filter) (string, error) {
+	return formatFilter(f, precOr)
+}
+
+// ---------------------------------
+// Lexer
+// ---------------------------------
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind tokKind
+	text string // The unquoted text of strings and identifiers.
+	raw  string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.raw)
+}
+
+// keyword reports whether the token is the unquoted keyword kw.
+func (t token) keyword(kw string) bool {
+	return t.kind == tokIdent && !strings.HasPrefix(t.raw, `"`) && strings.EqualFold(t.text, kw)
+}
+
+type filterLexer struct {
+	src string
+	pos int
+}
+
+func (l *filterLexer) errorf(pos int, format string, args ...interface{}) error {
+	return &FilterSyntaxError{Expr: l.src, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (l *filterLexer) next() (token, error) {
+	l.pos = skipSpace(l.src, l.pos)
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+	tok := func(kind tokKind, text string) (token, error) {
+		return token{kind: kind, text: text, raw: l.src[start:l.pos], pos: start}, nil
+	}
+
+	c := l.src[l.pos]
+	switch {
+	case c == '(':
+		l.pos++
+		return tok(tokLParen, "(")
+	case c == ')':
+		l.pos++
+		return tok(tokRParen, ")")
+	case c == ',':
+		l.pos++
+		return tok(tokComma, ",")
+	case c == '\'' || c == '"':
+		var b strings.Builder
+		for l.pos++; ; l.pos++ {
+			if l.pos >= len(l.src) {
+				return token{}, l.errorf(start, "unterminated quote")
+			}
+			if l.src[l.pos] == c {
+				if l.pos+1 < len(l.src) && l.src[l.pos+1] == c {
+					b.WriteByte(c)
+					l.pos++
+					continue
+				}
+				l.pos++
+				break
+			}
+			b.WriteByte(l.src[l.pos])
+		}
+		if c == '"' {
+			return tok(tokIdent, b.String())
+		}
+		return tok(tokString, b.String())
+	case strings.ContainsRune("=!<>~", rune(c)):
+		for _, op := range []string{"!=", "<>", "<=", ">=", "=", "<", ">", "~"} {
+			if strings.HasPrefix(l.src[l.pos:], op) {
+				l.pos += len(op)
+				return tok(tokOp, op)
+			}
+		}
+		return token{}, l.errorf(start, "unexpected %q", rune(c))
+	case c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+		l.pos++
+		for l.pos < len(l.src) && strings.ContainsRune("0123456789.eE+-", rune(l.src[l.pos])) {
+			l.pos++
+		}
+		text := l.src[start:l.pos]
+		if _, err := strconv.ParseFloat(text, 64); err != nil {
+			return token{}, l.errorf(start, "invalid number %q", text)
+		}
+		return tok(tokNumber, text)
+	}
+	if end := scanIdent(l.src, l.pos); end > l.pos {
+		l.pos = end
+		return tok(tokIdent, l.src[start:l.pos])
+	}
+	r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+	return token{}, l.errorf(start, "unexpected %q", r)
+}
+
+// skipSpace returns the position of the first character from pos which isn't a space.
+func skipSpace(src string, pos int) int {
+	for pos < len(src) {
+		r, size := utf8.DecodeRuneInString(src[pos:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		pos += size
+	}
+	return pos
+}
+
+// scanIdent returns the end of the unquoted identifier starting at pos, pos itself if there is none.
+// The identifiers start with a letter or '_', followed by letters, digits, '_', '.' and '$'.
+func scanIdent(src string, pos int) int {
+	start := pos
+	for pos < len(src) {
+		r, size := utf8.DecodeRuneInString(src[pos:])
+		if r != '_' && !unicode.IsLetter(r) && (pos == start || r != '.' && r != '$' && !unicode.IsDigit(r)) {
+			break
+		}
+		pos += size
+	}
+	return pos
+}
+
+// ---------------------------------
+// Parser
+// ---------------------------------
+
+type filterParser struct {
+	lex filterLexer
+	tok token
+}
+
+func (p *filterParser) advance() (err error) {
+	p.tok, err = p.lex.next()
+	return
+}
+
+func (p *filterParser) errorf(format string, args ...interface{}) error {
+	return p.lex.errorf(p.tok.pos, format, args...)
+}
+
+func (p *filterParser) parseOr() (*Filter, error) {
+	f, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	fields := []*Filter{f}
+	for p.tok.keyword("OR") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		f, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	return FilterOr(fields...), nil
+}
+
+func (p *filterParser) parseAnd() (*Filter, error) {
+	f, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	fields := []*Filter{f}
+	for p.tok.keyword("AND") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		f, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	return FilterAnd(fields...), nil
+}
+
+func (p *filterParser) parseNot() (*Filter, error) {
+	if p.tok.keyword("NOT") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		f, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return FilterNot(f), nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *filterParser) parsePrimary() (*Filter, error) {
+	switch p.tok.kind {
+	case tokLParen:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokRParen {
+			return nil, p.errorf("expected ')', got %s", p.tok)
+		}
+		return f, p.advance()
+	case tokIdent:
+		return p.parseComparison()
+	}
+	return nil, p.errorf("expected dimension or '(', got %s", p.tok)
+}
+
+func (p *filterParser) parseComparison() (*Filter, error) {
+	dim := p.tok.text
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	op := p.tok
+	switch {
+	case op.kind == tokOp:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		switch op.text {
+		case "=":
+			return FilterSelector(dim, v.value()), nil
+		case "!=", "<>":
+			return FilterNot(FilterSelector(dim, v.value())), nil
+		case "~":
+			if v.kind != tokString {
+				return nil, p.lex.errorf(v.pos, "regex pattern must be a string")
+			}
+			return FilterRegex(dim, v.text), nil
+		}
+		if v.kind == tokIdent {
+			return nil, p.lex.errorf(v.pos, "can't compare with null")
+		}
+		options := boundOptions(v)
+		switch op.text {
+		case "<":
+			options = append(options, Upper(v.text), UpperStrict(true))
+		case "<=":
+			options = append(options, Upper(v.text))
+		case ">":
+			options = append(options, Lower(v.text), LowerStrict(true))
+		case ">=":
+			options = append(options, Lower(v.text))
+		}
+		return FilterBound(dim, options...), nil
+
+	case op.keyword("IS"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		negate := false
+		if p.tok.keyword("NOT") {
+			negate = true
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+		if !p.tok.keyword("NULL") {
+			return nil, p.errorf("expected NULL, got %s", p.tok)
+		}
+		f := FilterSelector(dim, nil)
+		if negate {
+			f = FilterNot(f)
+		}
+		return f, p.advance()
+
+	case op.keyword("NOT"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if !p.tok.keyword("IN") {
+			return nil, p.errorf("expected IN, got %s", p.tok)
+		}
+		f, err := p.parseIn(dim)
+		if err != nil {
+			return nil, err
+		}
+		return FilterNot(f), nil
+
+	case op.keyword("IN"):
+		return p.parseIn(dim)
+
+	case op.keyword("BETWEEN"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		lower, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if !p.tok.keyword("AND") {
+			return nil, p.errorf("expected AND, got %s", p.tok)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		upper, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if lower.kind == tokIdent || upper.kind == tokIdent {
+			return nil, p.lex.errorf(lower.pos, "can't compare with null")
+		}
+		options := append(boundOptions(lower), Lower(lower.text), Upper(upper.text))
+		return FilterBound(dim, options...), nil
+	}
+	return nil, p.errorf("expected operator after %q, got %s", dim, p.tok)
+}
+
+func (p *filterParser) parseIn(dim string) (*Filter, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokLParen {
+		return nil, p.errorf("expected '(', got %s", p.tok)
+	}
+	var values []string
+	for {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if v.kind == tokIdent {
+			return nil, p.lex.errorf(v.pos, "null in IN list, use IS NULL")
+		}
+		values = append(values, v.text)
+		if p.tok.kind == tokRParen {
+			break
+		}
+		if p.tok.kind != tokComma {
+			return nil, p.errorf("expected ',' or ')', got %s", p.tok)
+		}
+	}
+	return FilterIn(dim, values...), p.advance()
+}
+
+// parseValue reads a string, a number or NULL, which is returned as an identifier token.
+func (p *filterParser) parseValue() (token, error) {
+	v := p.tok
+	switch {
+	case v.kind == tokString || v.kind == tokNumber || v.keyword("NULL"):
+		return v, p.advance()
+	}
+	return v, p.errorf("expected value, got %s", v)
+}
+
+func (t token) value() interface{} {
+	switch t.kind {
+	case tokIdent:
+		return nil
+	case tokNumber:
+		return json.Number(t.text)
+	}
+	return t.text
+}
+
+func boundOptions(v token) []FilterOption {
+	if v.kind == tokNumber {
+		return []FilterOption{BoundNumeric}
+	}
+	return nil
+}
+
+// ---------------------------------
+// Printer
+// ---------------------------------
+
+const (
+	precOr = iota
+	precAnd
+	precNot
+)
+
+func formatFilter(f *Filter, prec int) (string, error) {
+	if f == nil {
+		return "", fmt.Errorf("nil filter")
+	}
+	paren := func(s string, p int) string {
+		if p < prec {
+			return "(" + s + ")"
+		}
+		return s
+	}
+	join := func(connector string, p int) (string, error) {
+		parts := make([]string, len(f.Fields))
+		for i, field := range f.Fields {
+			s, err := formatFilter(field, p+1)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return paren(strings.Join(parts, " "+connector+" "), p), nil
+	}
+
+	if f.ExtractionFn != nil {
+		return "", fmt.Errorf("can't format %s filter on %q with an extraction function", f.Type, f.Dimension)
+	}
+	dim := formatIdent(f.Dimension)
+	switch f.Type {
+	case "and":
+		return join("AND", precAnd)
+	case "or":
+		return join("OR", precOr)
+	case "not":
+		if f.Field == nil {
+			return "", fmt.Errorf("not filter without field")
+		}
+		switch {
+		case f.Field.ExtractionFn != nil:
+		case f.Field.Type == "selector":
+			if f.Field.Value == nil {
+				return formatIdent(f.Field.Dimension) + " IS NOT NULL", nil
+			}
+			return formatIdent(f.Field.Dimension) + " != " + formatValue(f.Field.Value), nil
+		case f.Field.Type == "in":
+			return formatIdent(f.Field.Dimension) + " NOT IN " + formatValues(f.Field.Values), nil
+		}
+		s, err := formatFilter(f.Field, precNot)
+		if err != nil {
+			return "", err
+		}
+		return "NOT " + s, nil
+	case "selector":
+		if f.Value == nil {
+			return dim + " IS NULL", nil
+		}
+		return dim + " = " + formatValue(f.Value), nil
+	case "in":
+		return dim + " IN " + formatValues(f.Values), nil
+	case "regex":
+		return dim + " ~ " + formatString(f.Pattern), nil
+	case "bound":
+		ordering := boundOrdering(f)
+		if ordering != BoundLexicographic && ordering != BoundNumeric {
+			return "", fmt.Errorf("can't format bound filter with %s ordering", ordering)
+		}
+		numeric := ordering == BoundNumeric
+		for _, v := range []string{f.Lower, f.Upper} {
+			if _, err := strconv.ParseFloat(v, 64); err != nil && numeric && v != "" {
+				return "", fmt.Errorf("can't format numeric bound on %q with the value %q", f.Dimension, v)
+			}
+		}
+		value := func(s string) string {
+			if numeric {
+				return s
+			}
+			return formatString(s)
+		}
+		lowerStrict := boundLowerStrict(f)
+		upperStrict := boundUpperStrict(f)
+		switch {
+		case f.Lower != "" && f.Upper != "" && !lowerStrict && !upperStrict:
+			return dim + " BETWEEN " + value(f.Lower) + " AND " + value(f.Upper), nil
+		case f.Lower != "" && f.Upper != "":
+			lower, upper := " >= ", " <= "
+			if lowerStrict {
+				lower = " > "
+			}
+			if upperStrict {
+				upper = " < "
+			}
+			return paren(dim+lower+value(f.Lower)+" AND "+dim+upper+value(f.Upper), precAnd), nil
+		case f.Lower != "":
+			if lowerStrict {
+				return dim + " > " + value(f.Lower), nil
+			}
+			return dim + " >= " + value(f.Lower), nil
+		case f.Upper != "":
+			if upperStrict {
+				return dim + " < " + value(f.Upper), nil
+			}
+			return dim + " <= " + value(f.Upper), nil
+		}
+		return "", fmt.Errorf("bound filter on %q without bounds", f.Dimension)
+	}
+	return "", fmt.Errorf("can't format filter of type %q", f.Type)
+}
+
+func formatString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// formatValue formats the value of a selector, the numbers are left unquoted.
+func formatValue(v interface{}) string {
+	switch n := v.(type) {
+	case json.Number:
+		return n.String()
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(n)
+	case float32:
+		return formatFloat(float64(n), 32)
+	case float64:
+		return formatFloat(n, 64)
+	}
+	return formatString(fmt.Sprint(v))
+}
+
+func formatFloat(f float64, bitSize int) string {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return formatString(fmt.Sprint(f))
+	}
+	return strconv.FormatFloat(f, 'g', -1, bitSize)
+}
+
+func formatValues(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = formatString(v)
+	}
+	return "(" + strings.Join(quoted, ", ") + ")"
+}
+
+var filterKeywords = map[string]bool{"AND": true, "OR": true, "NOT": true, "IN": true, "IS": true, "NULL": true, "BETWEEN": true}
+
+func formatIdent(s string) string {
+	if s != "" && scanIdent(s, 0) == len(s) && !filterKeywords[strings.ToUpper(s)] {
+		return s
+	}
+	return `"` + strings.Replace(s, `"`, `""`, -1) + `"`
+}
@@ -0,0 +1,122 @@
+package godruid
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFilterDSL(t *testing.T) {
+	Convey("TestFilterDSL", t, func() {
+		Convey("parse", func() {
+			f, err := ParseFilter(`country = 'US' AND (device IN ('ios','android') OR NOT browser ~ '^Chrome')`)
+			So(err, ShouldBeNil)
+			So(f, ShouldResemble, FilterAnd(
+				FilterSelector("country", "US"),
+				FilterOr(
+					FilterIn("device", "ios", "android"),
+					FilterNot(FilterRegex("browser", "^Chrome")))))
+
+			f, err = ParseFilter(`a != 'it''s' or b is null and "my dim" is not null`)
+			So(err, ShouldBeNil)
+			So(f, ShouldResemble, FilterOr(
+				FilterNot(FilterSelector("a", "it's")),
+				FilterAnd(FilterSelector("b", nil), FilterNot(FilterSelector("my dim", nil)))))
+
+			f, err = ParseFilter(`age > 18 AND name < 'm' AND x BETWEEN 'a' AND 'b' AND y NOT IN ('1')`)
+			So(err, ShouldBeNil)
+			So(f, ShouldResemble, FilterAnd(
+				FilterBound("age", BoundNumeric, Lower("18"), LowerStrict(true)),
+				FilterBound("name", Upper("m"), UpperStrict(true)),
+				FilterBound("x", Lower("a"), Upper("b")),
+				FilterNot(FilterIn("y", "1"))))
+		})
+
+		Convey("errors", func() {
+			for expr, pos := range map[string]int{
+				"":                 0,
+				"a = ":             4,
+				"a = 'b":           4,
+				"a = 'b' AND":      11,
+				"(a = 'b'":         8,
+				"a IN ('b' 'c')":   10,
+				"a ~ 3":            4,
+				"a = 'b' c = 'd'":  8,
+				"a IS 'b'":         5,
+				"a # 'b'":          2,
+				"a BETWEEN 1 OR 2": 12,
+			} {
+				_, err := ParseFilter(expr)
+				So(err, ShouldHaveSameTypeAs, &FilterSyntaxError{})
+				So(err.(*FilterSyntaxError).Pos, ShouldEqual, pos)
+			}
+		})
+
+		Convey("format", func() {
+			for _, expr := range []string{
+				`country = 'US' AND (device IN ('ios', 'android') OR NOT browser ~ '^Chrome')`,
+				`a != 'it''s' OR b IS NULL AND "my dim" IS NOT NULL`,
+				`NOT (a = 'b' OR c NOT IN ('d'))`,
+				`age > 18 AND name < 'm' AND x BETWEEN 'a' AND 'b'`,
+				`x BETWEEN 1 AND 2 OR "and" >= '1'`,
+			} {
+				f, err := ParseFilter(expr)
+				So(err, ShouldBeNil)
+				s, err := FormatFilter(f)
+				So(err, ShouldBeNil)
+				So(s, ShouldEqual, expr)
+			}
+
+			s, err := FormatFilter(FilterBound("n", Lower("1"), Upper("5"), UpperStrict(true), BoundNumeric))
+			So(err, ShouldBeNil)
+			So(s, ShouldEqual, "n >= 1 AND n < 5")
+			s, err = FormatFilter(FilterBound("n", Lower("1"), BoundLexicographic))
+			So(err, ShouldBeNil)
+			So(s, ShouldEqual, "n >= '1'")
+			_, err = FormatFilter(FilterBound("n", Lower("a"), BoundNumeric))
+			So(err, ShouldNotBeNil)
+			_, err = FormatFilter(FilterBound("n", Lower("1"), AlphaNumeric(true)))
+			So(err, ShouldNotBeNil)
+
+			s, err = FormatFilter(FilterOr(FilterSelector("n", 5), FilterNot(FilterSelector("x", 2.5)), FilterSelector("s", "5")))
+			So(err, ShouldBeNil)
+			So(s, ShouldEqual, "n = 5 OR x != 2.5 OR s = '5'")
+			parsed, err := ParseFilter(s)
+			So(err, ShouldBeNil)
+			So(parsed, ShouldResemble, FilterOr(
+				FilterSelector("n", json.Number("5")), FilterNot(FilterSelector("x", json.Number("2.5"))), FilterSelector("s", "5")))
+			b1, _ := json.Marshal(parsed)
+			b2, _ := json.Marshal(FilterOr(FilterSelector("n", 5), FilterNot(FilterSelector("x", 2.5)), FilterSelector("s", "5")))
+			So(string(b1), ShouldEqual, string(b2))
+
+			for _, f := range []*Filter{
+				FilterSelector("a", "x", DimExFnUpper()),
+				FilterNot(FilterSelector("a", "x", DimExFnUpper())),
+				FilterInValues("a", []string{"x"}, DimExFnUpper()),
+				FilterBound("n", Lower("1"), DimExFnUpper()),
+			} {
+				_, err = FormatFilter(f)
+				So(err, ShouldNotBeNil)
+			}
+
+			for _, f := range []*Filter{
+				FilterSelector("café", "x"),
+				FilterAnd(FilterSelector("日本", "東京"), FilterIn("naïve-dim", "é")),
+				FilterSelector("$x", "y"),
+				FilterSelector("a\u00a0b", "y"),
+			} {
+				s, err := FormatFilter(f)
+				So(err, ShouldBeNil)
+				parsed, err := ParseFilter(s)
+				So(err, ShouldBeNil)
+				So(parsed, ShouldResemble, f)
+			}
+			s, _ = FormatFilter(FilterSelector("café", "x"))
+			So(s, ShouldEqual, `café = 'x'`)
+
+			_, err = FormatFilter(FilterJavaScript("a", "function(x) { return true }"))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
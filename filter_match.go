@@ -0,0 +1,480 @@
+package godruid
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Match evaluates the filter against a row, the way Druid would against the dimension values.
+// As in Druid, the null and the empty string are the same value, the numbers are compared as strings
+// and a multi-value dimension, given as a slice, matches if any of its values matches.
+// The interval filters read the times as milliseconds since the epoch or ISO-8601 strings,
+// the spatial ones read the coordinates as comma separated numbers.
+// A nil filter matches every row. The javascript filters and the extraction functions which can't
+// be computed locally, like timeFormat, javascript or the namespace lookups, return an error.
+//
+// Match compiles the filter on every call, use Matcher to evaluate it against many rows.
+func (f *Filter) Match(row map[string]interface{}) (bool, error) {
+	match, err := f.Matcher()
+	if err != nil {
+		return false, err
+	}
+	return match(row), nil
+}
+
+// Matcher compiles the filter into a function evaluating it against a row like Match, the regexes,
+// the intervals and the extraction functions are parsed once for all the rows.
+func (f *Filter) Matcher() (func(row map[string]interface{}) bool, error) {
+	if f == nil {
+		return func(map[string]interface{}) bool { return true }, nil
+	}
+	switch f.Type {
+	case "and", "or":
+		fields := make([]func(map[string]interface{}) bool, len(f.Fields))
+		for i, field := range f.Fields {
+			var err error
+			if fields[i], err = field.Matcher(); err != nil {
+				return nil, err
+			}
+		}
+		// An and matches unless a field doesn't, an or doesn't unless a field does.
+		all := f.Type == "and"
+		return func(row map[string]interface{}) bool {
+			for _, field := range fields {
+				if field(row) != all {
+					return !all
+				}
+			}
+			return all
+		}, nil
+	case "not":
+		field, err := f.Field.Matcher()
+		if err != nil {
+			return nil, err
+		}
+		return func(row map[string]interface{}) bool { return !field(row) }, nil
+	case "true":
+		return func(map[string]interface{}) bool { return true }, nil
+	case "false":
+		return func(map[string]interface{}) bool { return false }, nil
+	case "columnComparison":
+		return columnsMatcher(f.Dimensions)
+	}
+
+	var match func(v string) bool
+	switch f.Type {
+	case "selector", "extraction":
+		value := matchString(f.Value)
+		match = func(v string) bool { return v == value }
+	case "in":
+		values := make(map[string]bool, len(f.Values))
+		for _, v := range f.Values {
+			values[v] = true
+		}
+		match = func(v string) bool { return values[v] }
+	case "regex":
+		re, err := regexp.Compile(f.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		// Druid never matches the null value with a regex.
+		match = func(v string) bool { return v != "" && re.MatchString(v) }
+	case "like":
+		re, err := likeRegexp(f.Pattern, f.Escape)
+		if err != nil {
+			return nil, err
+		}
+		match = re.MatchString
+	case "interval":
+		ivs, err := ParseIntervals(f.Intervals)
+		if err != nil {
+			return nil, err
+		}
+		match = func(v string) bool {
+			t, err := parseRowTime(v)
+			if err != nil {
+				return false
+			}
+			for _, iv := range ivs {
+				if iv.Contains(t) {
+					return true
+				}
+			}
+			return false
+		}
+	case "spatial":
+		if f.Bound == nil {
+			return nil, fmt.Errorf("spatial filter on %q without bound", f.Dimension)
+		}
+		contains, err := spatialContains(f.Bound)
+		if err != nil {
+			return nil, err
+		}
+		match = func(v string) bool {
+			coords, ok := parseCoords(v)
+			return ok && contains(coords)
+		}
+	case "bound":
+		bounds := []*Filter{f}
+		match = func(v string) bool { return boundsContain(bounds, v) }
+		if f.Dimension == "__time" && f.ExtractionFn == nil && boundOrdering(f) == BoundNumeric {
+			// The bounds are milliseconds, the row times could also be ISO-8601 strings.
+			match = func(v string) bool {
+				t, err := parseRowTime(v)
+				if err != nil {
+					return false
+				}
+				return boundsContain(bounds, strconv.FormatInt(t.UnixMilli(), 10))
+			}
+		}
+	case "search":
+		if f.Query == nil || f.Query.Query == nil {
+			return nil, fmt.Errorf("search filter on %q without query", f.Dimension)
+		}
+		accept, err := searchAccept(f.Query.Query)
+		if err != nil {
+			return nil, err
+		}
+		match = func(v string) bool { return v != "" && accept(v) }
+	default:
+		return nil, fmt.Errorf("can't match filter of type %q", f.Type)
+	}
+
+	extract := func(v string) string { return v }
+	if f.ExtractionFn != nil {
+		var err error
+		if extract, err = extractionFunc(f.ExtractionFn); err != nil {
+			return nil, err
+		}
+	}
+	dim := f.Dimension
+	return func(row map[string]interface{}) bool {
+		for _, v := range rowValues(row, dim) {
+			if match(extract(v)) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// ---------------------------------
+// Helpers
+// ---------------------------------
+
+// matchString converts a value the way Druid stores it in a string dimension, nil is the empty string.
+func matchString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	}
+	return fmt.Sprint(v)
+}
+
+// rowValues returns the values of a dimension of a row, a missing or empty dimension has the null value.
+func rowValues(row map[string]interface{}, dim string) []string {
+	var values []string
+	switch v := row[dim].(type) {
+	case []interface{}:
+		for _, e := range v {
+			values = append(values, matchString(e))
+		}
+	case []string:
+		values = append(values, v...)
+	default:
+		values = append(values, matchString(v))
+	}
+	if len(values) == 0 {
+		values = []string{""}
+	}
+	return values
+}
+
+func searchAccept(q *SearchQuery) (func(string) bool, error) {
+	caseSensitive := q.CaseSensitive != nil && bool(*q.CaseSensitive)
+	fold := func(s string) string {
+		if caseSensitive {
+			return s
+		}
+		return strings.ToLower(s)
+	}
+	switch q.Type {
+	case "insensitive_contains":
+		value := strings.ToLower(q.Value)
+		return func(v string) bool { return strings.Contains(strings.ToLower(v), value) }, nil
+	case "contains":
+		value := fold(q.Value)
+		return func(v string) bool { return strings.Contains(fold(v), value) }, nil
+	case "fragment":
+		return func(v string) bool {
+			v = fold(v)
+			for _, value := range q.Values {
+				if !strings.Contains(v, fold(value)) {
+					return false
+				}
+			}
+			return true
+		}, nil
+	}
+	return nil, fmt.Errorf("can't evaluate search query of type %q", q.Type)
+}
+
+// extractionFunc returns the local implementation of an extraction function.
+func extractionFunc(fn *DimExtractionFn) (func(string) string, error) {
+	missing := func(v string) string {
+		if fn.RetainMissingValue != nil && bool(*fn.RetainMissingValue) {
+			return v
+		}
+		return fn.ReplaceMissingValueWith
+	}
+
+	switch fn.Type {
+	case "regex":
+		re, err := regexp.Compile(fn.Expr)
+		if err != nil {
+			return nil, err
+		}
+		index := fn.Index
+		if index == 0 {
+			index = 1
+		}
+		if index >= len(re.SubexpNames()) {
+			return nil, fmt.Errorf("regex %q has no group %d", fn.Expr, index)
+		}
+		return func(v string) string {
+			if m := re.FindStringSubmatch(v); m != nil {
+				return m[index]
+			}
+			if fn.ReplaceMissingValue != nil && bool(*fn.ReplaceMissingValue) {
+				return fn.ReplaceMissingValueWith
+			}
+			return v
+		}, nil
+	case "partial":
+		re, err := regexp.Compile(fn.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return func(v string) string {
+			if re.MatchString(v) {
+				return v
+			}
+			return ""
+		}, nil
+	case "searchQuery":
+		if fn.Query == nil {
+			return nil, fmt.Errorf("searchQuery extraction function without query")
+		}
+		accept, err := searchAccept(fn.Query)
+		if err != nil {
+			return nil, err
+		}
+		return func(v string) string {
+			if v != "" && accept(v) {
+				return v
+			}
+			return ""
+		}, nil
+	case "substring":
+		if fn.Index < 0 || fn.Length < 0 {
+			return nil, fmt.Errorf("substring extraction function with a negative index %d or length %d", fn.Index, fn.Length)
+		}
+		// Druid counts characters, not bytes.
+		return func(v string) string {
+			runes := []rune(v)
+			if fn.Index >= len(runes) {
+				return ""
+			}
+			end := len(runes)
+			if fn.Length > 0 && fn.Index+fn.Length < end {
+				end = fn.Index + fn.Length
+			}
+			return string(runes[fn.Index:end])
+		}, nil
+	case "stringFormat":
+		return func(v string) string { return fmt.Sprintf(fn.Format, v) }, nil
+	case "upper":
+		return strings.ToUpper, nil
+	case "lower":
+		return strings.ToLower, nil
+	case "lookup":
+		if fn.Lookup == nil || fn.Lookup.Type != "map" {
+			return nil, fmt.Errorf("can't evaluate lookup extraction function without map")
+		}
+		return func(v string) string {
+			if mapped, ok := fn.Lookup.Map[v]; ok {
+				return mapped
+			}
+			return missing(v)
+		}, nil
+	case "cascade":
+		fns := make([]func(string) string, len(fn.ExtractionFns))
+		for i, f := range fn.ExtractionFns {
+			var err error
+			if fns[i], err = extractionFunc(f); err != nil {
+				return nil, err
+			}
+		}
+		return func(v string) string {
+			for _, f := range fns {
+				v = f(v)
+			}
+			return v
+		}, nil
+	}
+	return nil, fmt.Errorf("can't evaluate extraction function of type %q", fn.Type)
+}
+
+// columnsMatcher returns a matcher reporting whether the values of every pair of dimensions overlap.
+func columnsMatcher(dims []DimSpec) (func(row map[string]interface{}) bool, error) {
+	type column struct {
+		dimension string
+		extract   func(string) string
+	}
+	columns := make([]column, len(dims))
+	for i, dim := range dims {
+		switch d := dim.(type) {
+		case string:
+			columns[i].dimension = d
+		case *Dimension:
+			columns[i].dimension = d.Dimension
+			if d.DimExtractionFn != nil {
+				var err error
+				if columns[i].extract, err = extractionFunc(d.DimExtractionFn); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			return nil, fmt.Errorf("can't compare dimension spec %T", dim)
+		}
+	}
+	return func(row map[string]interface{}) bool {
+		values := make([][]string, len(columns))
+		for i, c := range columns {
+			values[i] = rowValues(row, c.dimension)
+			if c.extract != nil {
+				for j, v := range values[i] {
+					values[i][j] = c.extract(v)
+				}
+			}
+		}
+		for i := 1; i < len(values); i++ {
+			if len(intersectValues(values[0], values[i])) == 0 {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// likeRegexp translates a SQL LIKE pattern, '%' matches any string and '_' any character.
+func likeRegexp(pattern, escape string) (*regexp.Regexp, error) {
+	if len([]rune(escape)) > 1 {
+		return nil, fmt.Errorf("like escape %q is more than one character", escape)
+	}
+	var b strings.Builder
+	b.WriteString("^(?s:")
+	escaped := false
+	for _, c := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+			escaped = false
+		case escape != "" && string(c) == escape:
+			escaped = true
+		case c == '%':
+			b.WriteString(".*")
+		case c == '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString(")$")
+	return regexp.Compile(b.String())
+}
+
+// parseRowTime parses a time given as milliseconds since the epoch or as an ISO-8601 string.
+func parseRowTime(v string) (time.Time, error) {
+	if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.UnixMilli(ms).UTC(), nil
+	}
+	return parseIntervalTime(v)
+}
+
+func parseCoords(v string) ([]float64, bool) {
+	if v == "" {
+		return nil, false
+	}
+	parts := strings.Split(v, ",")
+	coords := make([]float64, len(parts))
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, false
+		}
+		coords[i] = f
+	}
+	return coords, true
+}
+
+func spatialContains(b *SpatialBound) (func([]float64) bool, error) {
+	switch b.Type {
+	case "rectangular":
+		if len(b.MinCoords) != len(b.MaxCoords) {
+			return nil, fmt.Errorf("rectangular bound with %d min and %d max coordinates", len(b.MinCoords), len(b.MaxCoords))
+		}
+		return func(coords []float64) bool {
+			if len(coords) != len(b.MinCoords) {
+				return false
+			}
+			for i, c := range coords {
+				if c < b.MinCoords[i] || c > b.MaxCoords[i] {
+					return false
+				}
+			}
+			return true
+		}, nil
+	case "radius":
+		return func(coords []float64) bool {
+			if len(coords) != len(b.Coords) {
+				return false
+			}
+			var sum float64
+			for i, c := range coords {
+				sum += (c - b.Coords[i]) * (c - b.Coords[i])
+			}
+			return math.Sqrt(sum) <= b.Radius
+		}, nil
+	case "polygon":
+		if len(b.Abscissa) != len(b.Ordinate) || len(b.Abscissa) < 3 {
+			return nil, fmt.Errorf("polygon bound needs as many abscissas as ordinates, at least 3")
+		}
+		return func(coords []float64) bool {
+			if len(coords) != 2 {
+				return false
+			}
+			// Ray casting, the point is inside if it crosses an odd number of edges.
+			x, y := coords[0], coords[1]
+			inside := false
+			for i, j := 0, len(b.Abscissa)-1; i < len(b.Abscissa); j, i = i, i+1 {
+				xi, yi, xj, yj := b.Abscissa[i], b.Ordinate[i], b.Abscissa[j], b.Ordinate[j]
+				if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+					inside = !inside
+				}
+			}
+			return inside
+		}, nil
+	}
+	return nil, fmt.Errorf("can't evaluate spatial bound of type %q", b.Type)
+}
@@ -0,0 +1,190 @@
+package godruid
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFilterMatch(t *testing.T) {
+	Convey("TestFilterMatch", t, func() {
+		row := map[string]interface{}{
+			"country": "US",
+			"device":  "ios",
+			"browser": "Chrome 50",
+			"version": "v10",
+			"count":   12.0,
+			"tags":    []interface{}{"a", "b"},
+			"empty":   "",
+		}
+		match := func(f *Filter) bool {
+			ok, err := f.Match(row)
+			So(err, ShouldBeNil)
+			return ok
+		}
+		expr := func(s string) *Filter {
+			f, err := ParseFilter(s)
+			So(err, ShouldBeNil)
+			return f
+		}
+
+		So(match(nil), ShouldBeTrue)
+		So(match(expr(`country = 'US' AND (device IN ('ios','android') OR NOT browser ~ '^Chrome')`)), ShouldBeTrue)
+		So(match(expr(`country = 'US' AND NOT browser ~ '^Chrome'`)), ShouldBeFalse)
+		So(match(expr(`count = '12' AND tags = 'b' AND tags != 'c'`)), ShouldBeTrue)
+		So(match(FilterFalse()), ShouldBeFalse)
+
+		Convey("null", func() {
+			So(match(FilterSelector("missing", nil)), ShouldBeTrue)
+			So(match(FilterSelector("missing", "")), ShouldBeTrue)
+			So(match(FilterSelector("empty", nil)), ShouldBeTrue)
+			So(match(FilterSelector("country", nil)), ShouldBeFalse)
+			So(match(FilterIn("missing", "", "x")), ShouldBeTrue)
+			ok, _ := FilterSelector("tags", nil).Match(map[string]interface{}{"tags": []interface{}{}})
+			So(ok, ShouldBeTrue)
+
+			So(match(FilterRegex("missing", ".*")), ShouldBeFalse)
+			So(match(FilterRegex("empty", "^$")), ShouldBeFalse)
+			So(match(FilterNot(FilterRegex("missing", ".*"))), ShouldBeTrue)
+		})
+
+		Convey("bound", func() {
+			So(match(expr(`count > 9`)), ShouldBeTrue)
+			So(match(expr(`count > '9'`)), ShouldBeFalse)
+			So(match(expr(`version BETWEEN 'v9' AND 'v11'`)), ShouldBeFalse)
+			So(match(FilterBound("version", Lower("v9"), Upper("v11"), AlphaNumeric(true))), ShouldBeTrue)
+			So(match(expr(`count >= 12 AND count < 13`)), ShouldBeTrue)
+			So(match(expr(`count > 12`)), ShouldBeFalse)
+		})
+
+		Convey("ordering", func() {
+			row["price"] = "9.5"
+			row["__time"] = 1462060800000.0 // 2016-05-01
+			So(match(FilterFloatRange("price", 9.5, 10)), ShouldBeTrue)
+			So(match(FilterFloatRange("price", 9.5, 10, LowerStrict(true))), ShouldBeFalse)
+			So(match(FilterFloatRange("price", math.Inf(-1), 10)), ShouldBeTrue)
+			So(match(FilterIntRange("count", 2, 100)), ShouldBeTrue)
+			So(match(FilterIntRange("country", math.MinInt64, math.MaxInt64)), ShouldBeFalse)
+			So(match(FilterBound("browser", Lower("Chrome 9"), BoundVersion)), ShouldBeTrue)
+			So(match(FilterBound("browser", Upper("Chrome 9"))), ShouldBeTrue)
+			So(match(FilterBound("device", Lower("zz"), Upper("aaaa"), BoundStrlen)), ShouldBeTrue)
+			So(match(FilterBound("device", Upper("zz"), BoundStrlen)), ShouldBeFalse)
+
+			may := time.Date(2016, 5, 1, 0, 0, 0, 0, time.UTC)
+			So(match(FilterTimeRange(may, may.AddDate(0, 0, 1))), ShouldBeTrue)
+			So(match(FilterTimeRange(may.AddDate(0, 0, -1), may)), ShouldBeFalse)
+			So(match(FilterTimeRange(may.AddDate(0, 0, -1), may, UpperStrict(false))), ShouldBeTrue)
+			So(match(FilterTimeRange(time.Time{}, may.AddDate(0, 0, 1))), ShouldBeTrue)
+			farFuture := time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+			So(FilterTimeRange(may, farFuture).Upper, ShouldEqual, "253402214400000")
+			So(match(FilterTimeRange(may, farFuture)), ShouldBeTrue)
+
+			iso := map[string]interface{}{"__time": "2016-05-01T12:00:00.000Z"}
+			matcher, err := FilterTimeRange(may, may.AddDate(0, 0, 1)).Matcher()
+			So(err, ShouldBeNil)
+			So(matcher(iso), ShouldBeTrue)
+			iso["__time"] = "2016-05-02T00:00:00Z"
+			So(matcher(iso), ShouldBeFalse)
+			iso["__time"] = "not a time"
+			So(matcher(iso), ShouldBeFalse)
+
+			b, err := json.Marshal(FilterTimeRange(may, may.AddDate(0, 0, 1)))
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, `{"type":"bound","dimension":"__time","lower":"1462060800000","upper":"1462147200000","upperStrict":true,"ordering":"numeric"}`)
+			b, err = json.Marshal(FilterFloatRange("price", 0.25, math.Inf(1)))
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, `{"type":"bound","dimension":"price","lower":"0.25","ordering":"numeric"}`)
+
+			f := Simplify(FilterAnd(FilterIntRange("n", 1, 100), FilterIntRange("n", 20, 1000, UpperStrict(true))), SingleValued(true))
+			So(f, ShouldResemble, FilterBound("n", BoundNumeric, Lower("20"), Upper("100")))
+			So(Simplify(FilterAnd(FilterIntRange("n", 1, 9), FilterIntRange("n", 10, 20)), SingleValued(true)), ShouldResemble, FilterFalse())
+		})
+
+		Convey("search", func() {
+			So(match(FilterSearch("browser", &QuerySearch{Query: SearchQueryInsensitiveContains("chrome")})), ShouldBeTrue)
+			So(match(FilterSearch("browser", &QuerySearch{Query: SearchQueryContains("chrome", CaseSensitive(true))})), ShouldBeFalse)
+			So(match(FilterSearch("browser", &QuerySearch{Query: SearchQueryFragmentSearch([]string{"chr", "50"})})), ShouldBeTrue)
+			So(match(FilterSearch("missing", &QuerySearch{Query: SearchQueryContains("")})), ShouldBeFalse)
+		})
+
+		Convey("extraction", func() {
+			So(match(FilterExtraction("browser", "Chrome", DimExFnRegex(`^(\w+)`))), ShouldBeTrue)
+			So(match(FilterExtraction("browser", "Chr", DimExFnSubstringQuerySpec(0, Length(3)))), ShouldBeTrue)
+			So(match(FilterExtraction("country", "United States", DimExFnLookup(LookupMap(map[string]string{"US": "United States"})))), ShouldBeTrue)
+			So(match(FilterExtraction("country", "", DimExFnLookup(LookupMap(map[string]string{"FR": "France"})))), ShouldBeTrue)
+			So(match(FilterExtraction("device", "IOS", DimExFnCascade(DimExFnPartial("^i"), DimExFnUpper()))), ShouldBeTrue)
+			So(match(FilterInValues("device", []string{"IOS", "ANDROID"}, DimExFnUpper())), ShouldBeTrue)
+			So(match(FilterInValues("device", []string{"ios"}, DimExFnUpper())), ShouldBeFalse)
+
+			b, err := json.Marshal(FilterInValues("device", []string{"IOS"}, DimExFnUpper()))
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, `{"type":"in","dimension":"device","extractionFn":{"type":"upper"},"values":["IOS"]}`)
+
+			_, err = FilterExtraction("device", "x", DimExFnJavascript("function(x) { return x }")).Match(row)
+			So(err, ShouldNotBeNil)
+			_, err = FilterJavaScript("device", "function(x) { return true }").Match(row)
+			So(err, ShouldNotBeNil)
+
+			So(match(FilterExtraction("browser", "50", DimExFnSubstringQuerySpec(7))), ShouldBeTrue)
+			So(match(FilterExtraction("browser", "", DimExFnSubstringQuerySpec(20, Length(3)))), ShouldBeTrue)
+			city := map[string]interface{}{"city": "éa"}
+			ok, err := FilterExtraction("city", "é", DimExFnSubstringQuerySpec(0, Length(1))).Match(city)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			ok, err = FilterExtraction("city", "a", DimExFnSubstringQuerySpec(1)).Match(city)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			_, err = FilterExtraction("browser", "x", DimExFnSubstringQuerySpec(-1)).Match(row)
+			So(err, ShouldNotBeNil)
+			_, err = FilterExtraction("browser", "x", DimExFnSubstringQuerySpec(0, Length(-2))).Match(row)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("matcher", func() {
+			matcher, err := expr(`browser ~ '^Chrome' AND (device = 'android' OR tags = 'b')`).Matcher()
+			So(err, ShouldBeNil)
+			So(matcher(row), ShouldBeTrue)
+			So(matcher(map[string]interface{}{"browser": "Chrome 51", "device": "android"}), ShouldBeTrue)
+			So(matcher(map[string]interface{}{"browser": "Firefox", "device": "android"}), ShouldBeFalse)
+			So(matcher(map[string]interface{}{"browser": "Chrome 51", "tags": []string{"c"}}), ShouldBeFalse)
+
+			_, err = FilterOr(FilterTrue(), FilterRegex("browser", "(")).Matcher()
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("like, interval, columnComparison, spatial", func() {
+			row["__time"] = 1462060800000.0 // 2016-05-01
+			row["os"] = "IOS"
+			row["pct"] = "50%"
+			row["loc"] = "1.5,2.5"
+
+			So(match(FilterLike("browser", "Chr_me%")), ShouldBeTrue)
+			So(match(FilterLike("browser", "chr%", DimExFnUpper())), ShouldBeFalse)
+			So(match(FilterLike("browser", "CHR%", DimExFnUpper())), ShouldBeTrue)
+			So(match(FilterLike("pct", `50\%`, Escape(`\`))), ShouldBeTrue)
+			So(match(FilterLike("pct", `5\%`, Escape(`\`))), ShouldBeFalse)
+
+			So(match(FilterInterval("__time", []string{"2016-05-01/2016-05-02"})), ShouldBeTrue)
+			So(match(FilterInterval("__time", []string{"2016-04-01/2016-05-01"})), ShouldBeFalse)
+
+			So(match(FilterColumnComparison("device", "os")), ShouldBeFalse)
+			So(match(FilterColumnComparison(DimExtraction("device", "device", DimExFnUpper()), "os")), ShouldBeTrue)
+
+			So(match(FilterSpatial("loc", SpatialRectangular([]float64{1, 2}, []float64{2, 3}))), ShouldBeTrue)
+			So(match(FilterSpatial("loc", SpatialRadius([]float64{0, 0}, 2))), ShouldBeFalse)
+			So(match(FilterSpatial("loc", SpatialPolygon([]float64{0, 5, 0}, []float64{0, 0, 5}))), ShouldBeTrue)
+			So(match(FilterSpatial("loc", SpatialPolygon([]float64{0, 1, 0}, []float64{0, 0, 1}))), ShouldBeFalse)
+			So(match(FilterTrue()), ShouldBeTrue)
+
+			b, err := json.Marshal(FilterLike("d", "a!%%", Escape("!"), DimExFnLower()))
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, `{"type":"like","dimension":"d","pattern":"a!%%","extractionFn":{"type":"lower"},"escape":"!"}`)
+			b, err = json.Marshal(FilterSpatial("loc", SpatialRadius([]float64{1, 2}, 3)))
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, `{"type":"spatial","dimension":"loc","bound":{"type":"radius","coords":[1,2],"radius":3}}`)
+		})
+	})
+}
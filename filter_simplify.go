@@ -0,0 +1,487 @@
+package godruid
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+type simplifyConfig struct {
+	singleValued bool
+}
+
+type SimplifyOption interface {
+	apply(*simplifyConfig)
+}
+
+// SingleValued tells Simplify that every dimension has at most one value per row,
+// which allows it to merge the terms of an and on the same dimension.
+type SingleValued bool
+
+func (b SingleValued) apply(c *simplifyConfig) { c.singleValued = bool(b) }
+
+// Simplify returns an equivalent filter with less nodes, the filter itself is left untouched.
+// The nested ands and ors are flattened, the double nots removed and the duplicated fields dropped.
+// The selectors and ins on the same dimension are merged into an in for or.
+// FilterTrue and FilterFalse are folded into their parents, like the and combinations of a filter
+// and its negation, which become FilterFalse.
+// The filters with an extractionFn are kept as is.
+//
+// A row of a multi-value dimension matches a = 'x' AND a = 'y' when it has both values, so the terms
+// of an and on the same dimension are only merged with SingleValued(true): their values are intersected,
+// like their bounds, and the combinations which can't match, like a = 'x' AND a = 'y', become FilterFalse.
+func Simplify(f *Filter, options ...SimplifyOption) *Filter {
+	conf := &simplifyConfig{}
+	for _, opt := range options {
+		opt.apply(conf)
+	}
+	return conf.simplify(f)
+}
+
+func (c *simplifyConfig) simplify(f *Filter) *Filter {
+	if f == nil {
+		return nil
+	}
+	switch f.Type {
+	case "and":
+		return c.simplifyAnd(f.Fields)
+	case "or":
+		return c.simplifyOr(f.Fields)
+	case "not":
+		field := c.simplify(f.Field)
+		switch {
+		case field == nil:
+		case field.Type == "not":
+			return field.Field
+		case field.Type == "true":
+			return FilterFalse()
+		case field.Type == "false":
+			return FilterTrue()
+		}
+		return FilterNot(field)
+	case "in":
+		if f.ExtractionFn == nil {
+			return equalityFilter(f.Dimension, dedupValues(f.Values))
+		}
+	}
+	return f
+}
+
+func (c *simplifyConfig) simplifyAnd(fields []*Filter) *Filter {
+	var terms []*Filter
+	hasTrue := false
+	for _, field := range fields {
+		field = c.simplify(field)
+		switch {
+		case field == nil:
+		case field.Type == "true":
+			hasTrue = true
+		case field.Type == "false":
+			return FilterFalse()
+		case field.Type == "and":
+			terms = append(terms, field.Fields...)
+		default:
+			terms = append(terms, field)
+		}
+	}
+
+	out := terms
+	if c.singleValued {
+		var ok bool
+		if out, ok = mergeDimensions(terms); !ok {
+			return FilterFalse()
+		}
+	}
+	out = dedupFilters(out)
+	for _, term := range out {
+		if term.Type != "not" {
+			continue
+		}
+		for _, other := range out {
+			if reflect.DeepEqual(term.Field, other) {
+				return FilterFalse()
+			}
+		}
+	}
+	if len(out) == 0 && hasTrue {
+		return FilterTrue()
+	}
+	return joinFilters(out, "and")
+}
+
+// mergeDimensions merges the values and the bounds of the and terms by dimension, at the position
+// of their first term. It returns false if the terms of a dimension can't all match a single value.
+func mergeDimensions(terms []*Filter) ([]*Filter, bool) {
+	type dimTerms struct {
+		values    []string
+		hasValues bool
+		bounds    []*Filter
+	}
+	dims := map[string]*dimTerms{}
+	first := map[int]string{}
+	var rest []*Filter
+	var restPos []int
+	for i, term := range terms {
+		values, isEquality := equalityValues(term)
+		isBound := term.Type == "bound" && term.ExtractionFn == nil
+		if !isEquality && !isBound {
+			rest, restPos = append(rest, term), append(restPos, i)
+			continue
+		}
+		d := dims[term.Dimension]
+		if d == nil {
+			d = &dimTerms{}
+			dims[term.Dimension] = d
+			first[i] = term.Dimension
+		}
+		switch {
+		case isBound:
+			d.bounds = append(d.bounds, term)
+		case d.hasValues:
+			d.values = intersectValues(d.values, values)
+		default:
+			d.values, d.hasValues = dedupValues(values), true
+		}
+	}
+
+	var out []*Filter
+	for i, j := 0, 0; i < len(terms); i++ {
+		if j < len(restPos) && restPos[j] == i {
+			out = append(out, rest[j])
+			j++
+			continue
+		}
+		dim, ok := first[i]
+		if !ok {
+			continue
+		}
+		d := dims[dim]
+		if d.hasValues {
+			var values []string
+			for _, v := range d.values {
+				if boundsContain(d.bounds, v) {
+					values = append(values, v)
+				}
+			}
+			if len(values) == 0 {
+				return nil, false
+			}
+			out = append(out, equalityFilter(dim, values))
+			continue
+		}
+		bounds, ok := intersectBounds(d.bounds)
+		if !ok {
+			return nil, false
+		}
+		out = append(out, bounds...)
+	}
+	return out, true
+}
+
+func (c *simplifyConfig) simplifyOr(fields []*Filter) *Filter {
+	var terms []*Filter
+	hasFalse := false
+	for _, field := range fields {
+		field = c.simplify(field)
+		switch {
+		case field == nil:
+		case field.Type == "true":
+			return FilterTrue()
+		case field.Type == "false":
+			hasFalse = true
+		case field.Type == "or":
+			terms = append(terms, field.Fields...)
+		default:
+			terms = append(terms, field)
+		}
+	}
+
+	values := map[string][]string{}
+	var dims []string
+	firstPos := map[string]int{}
+	var out []*Filter
+	for _, term := range terms {
+		vs, ok := equalityValues(term)
+		if !ok {
+			out = append(out, term)
+			continue
+		}
+		if _, seen := values[term.Dimension]; !seen {
+			dims = append(dims, term.Dimension)
+			firstPos[term.Dimension] = len(out)
+			out = append(out, nil)
+		}
+		values[term.Dimension] = append(values[term.Dimension], vs...)
+	}
+	for _, dim := range dims {
+		out[firstPos[dim]] = equalityFilter(dim, dedupValues(values[dim]))
+	}
+
+	out = dedupFilters(out)
+	if len(out) == 0 && hasFalse {
+		return FilterFalse()
+	}
+	return joinFilters(out, "or")
+}
+
+// ---------------------------------
+// Helpers
+// ---------------------------------
+
+// equalityValues returns the values matched by a selector on a string or an in without extractionFn.
+func equalityValues(f *Filter) ([]string, bool) {
+	if f.ExtractionFn != nil {
+		return nil, false
+	}
+	switch f.Type {
+	case "selector":
+		if v, ok := f.Value.(string); ok {
+			return []string{v}, true
+		}
+	case "in":
+		return f.Values, true
+	}
+	return nil, false
+}
+
+// equalityFilter returns a selector for a single value, an in otherwise.
+func equalityFilter(dim string, values []string) *Filter {
+	if len(values) == 1 {
+		return FilterSelector(dim, values[0])
+	}
+	return FilterIn(dim, values...)
+}
+
+func dedupValues(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	res := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+func intersectValues(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, v := range b {
+		in[v] = true
+	}
+	var res []string
+	for _, v := range a {
+		if in[v] {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+func dedupFilters(filters []*Filter) []*Filter {
+	var res []*Filter
+	for _, f := range filters {
+		dup := false
+		for _, g := range res {
+			if reflect.DeepEqual(f, g) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+// intersectBounds merges the bounds of a dimension having the same ordering,
+// it returns false if one of the intersections is empty.
+func intersectBounds(bounds []*Filter) ([]*Filter, bool) {
+	var res []*Filter
+	for _, b := range bounds {
+		merged := false
+		for i, r := range res {
+			if boundOrdering(r) != boundOrdering(b) {
+				continue
+			}
+			res[i], merged = intersectBound(r, b), true
+			break
+		}
+		if !merged {
+			res = append(res, b)
+		}
+	}
+	for _, r := range res {
+		if r.Lower == "" || r.Upper == "" {
+			continue
+		}
+		c := compareBound(r, r.Lower, r.Upper)
+		if c > 0 || c == 0 && (boundLowerStrict(r) || boundUpperStrict(r)) {
+			return nil, false
+		}
+	}
+	return res, true
+}
+
+func intersectBound(a, b *Filter) *Filter {
+	lower, lowerStrict := a.Lower, boundLowerStrict(a)
+	if b.Lower != "" {
+		c := compareBound(a, b.Lower, lower)
+		switch {
+		case lower == "" || c > 0:
+			lower, lowerStrict = b.Lower, boundLowerStrict(b)
+		case c == 0:
+			lowerStrict = lowerStrict || boundLowerStrict(b)
+		}
+	}
+	upper, upperStrict := a.Upper, boundUpperStrict(a)
+	if b.Upper != "" {
+		c := compareBound(a, b.Upper, upper)
+		switch {
+		case upper == "" || c < 0:
+			upper, upperStrict = b.Upper, boundUpperStrict(b)
+		case c == 0:
+			upperStrict = upperStrict || boundUpperStrict(b)
+		}
+	}
+
+	var options []FilterOption
+	switch {
+	case a.Ordering != "":
+		options = append(options, a.Ordering)
+	case boundAlphaNumeric(a):
+		options = append(options, AlphaNumeric(true))
+	}
+	if lower != "" {
+		options = append(options, Lower(lower))
+		if lowerStrict {
+			options = append(options, LowerStrict(true))
+		}
+	}
+	if upper != "" {
+		options = append(options, Upper(upper))
+		if upperStrict {
+			options = append(options, UpperStrict(true))
+		}
+	}
+	return FilterBound(a.Dimension, options...)
+}
+
+// boundsContain reports whether the value is within all the bounds.
+func boundsContain(bounds []*Filter, v string) bool {
+	for _, b := range bounds {
+		if boundOrdering(b) == BoundNumeric {
+			if _, err := strconv.ParseFloat(v, 64); err != nil {
+				return false
+			}
+		}
+		if b.Lower != "" {
+			c := compareBound(b, v, b.Lower)
+			if c < 0 || c == 0 && boundLowerStrict(b) {
+				return false
+			}
+		}
+		if b.Upper != "" {
+			c := compareBound(b, v, b.Upper)
+			if c > 0 || c == 0 && boundUpperStrict(b) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// compareBound compares two values in the ordering of the bound.
+func compareBound(b *Filter, x, y string) int {
+	switch boundOrdering(b) {
+	case BoundAlphanumeric:
+		return compareAlphaNumeric(x, y)
+	case BoundNumeric:
+		// The values which aren't numbers come first.
+		fx, errx := strconv.ParseFloat(x, 64)
+		fy, erry := strconv.ParseFloat(y, 64)
+		switch {
+		case errx != nil || erry != nil:
+			return cmpInt(boolInt(errx == nil), boolInt(erry == nil))
+		case fx < fy:
+			return -1
+		case fx > fy:
+			return 1
+		}
+		return 0
+	case BoundStrlen:
+		if len(x) != len(y) {
+			return cmpInt(len(x), len(y))
+		}
+	case BoundVersion:
+		return compareVersions(x, y)
+	}
+	return strings.Compare(x, y)
+}
+
+// boundOrdering returns the ordering of a bound, set by Ordering or by the older AlphaNumeric flag.
+func boundOrdering(b *Filter) BoundOrdering {
+	switch {
+	case b.Ordering != "":
+		return b.Ordering
+	case boundAlphaNumeric(b):
+		return BoundAlphanumeric
+	}
+	return BoundLexicographic
+}
+
+func boundAlphaNumeric(b *Filter) bool {
+	return b.AlphaNumeric != nil && bool(*b.AlphaNumeric)
+}
+
+// compareVersions compares the versions part by part, numerically when both parts are numbers.
+// The parts are the runs of digits and of other characters, the dots and dashes only separate them.
+func compareVersions(x, y string) int {
+	px, py := versionParts(x), versionParts(y)
+	for i := 0; i < len(px) && i < len(py); i++ {
+		nx, errx := strconv.ParseInt(px[i], 10, 64)
+		ny, erry := strconv.ParseInt(py[i], 10, 64)
+		if errx == nil && erry == nil {
+			if nx != ny {
+				return cmpInt(boolInt(nx > ny), boolInt(nx < ny))
+			}
+			continue
+		}
+		if c := strings.Compare(px[i], py[i]); c != 0 {
+			return c
+		}
+	}
+	return cmpInt(len(px), len(py))
+}
+
+func versionParts(v string) []string {
+	var parts []string
+	for v != "" {
+		if v[0] == '.' || v[0] == '-' {
+			v = v[1:]
+			continue
+		}
+		n := 1
+		for n < len(v) && v[n] != '.' && v[n] != '-' && isDigit(v[n]) == isDigit(v[0]) {
+			n++
+		}
+		parts, v = append(parts, v[:n]), v[n:]
+	}
+	return parts
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func boundLowerStrict(b *Filter) bool {
+	return b.LowerStrict != nil && bool(*b.LowerStrict)
+}
+
+func boundUpperStrict(b *Filter) bool {
+	return b.UpperStrict != nil && bool(*b.UpperStrict)
+}
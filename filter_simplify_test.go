@@ -0,0 +1,65 @@
+package godruid
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSimplify(t *testing.T) {
+	Convey("TestSimplify", t, func() {
+		simplify := func(expr string, options ...SimplifyOption) string {
+			f, err := ParseFilter(expr)
+			So(err, ShouldBeNil)
+			f = Simplify(f, options...)
+			if f != nil && f.Type == "false" {
+				return "FALSE"
+			}
+			s, err := FormatFilter(f)
+			So(err, ShouldBeNil)
+			return s
+		}
+
+		So(Simplify(nil), ShouldBeNil)
+		So(simplify(`NOT NOT a = 'x'`), ShouldEqual, `a = 'x'`)
+		So(simplify(`a = 'x' AND (b = 'y' AND (c = 'z' AND d = 'w'))`), ShouldEqual, `a = 'x' AND b = 'y' AND c = 'z' AND d = 'w'`)
+		So(simplify(`a = 'x' OR b = 'y' OR a = 'z' OR a IN ('x', 'w')`), ShouldEqual, `a IN ('x', 'z', 'w') OR b = 'y'`)
+		So(simplify(`a IN ('x', 'y') AND b = 'z' AND a IN ('y', 'w')`, SingleValued(true)), ShouldEqual, `a = 'y' AND b = 'z'`)
+		So(simplify(`a = 'x' AND a = 'y'`, SingleValued(true)), ShouldEqual, "FALSE")
+		So(simplify(`a = 'x' AND b = 'y' AND NOT a = 'x'`), ShouldEqual, "FALSE")
+		So(simplify(`(a = 'x' AND a = 'y') OR b = 'z'`, SingleValued(true)), ShouldEqual, `b = 'z'`)
+		So(simplify(`b = 'z' AND b = 'z'`), ShouldEqual, `b = 'z'`)
+		So(Simplify(FilterAnd(FilterTrue(), FilterSelector("a", "x"))), ShouldResemble, FilterSelector("a", "x"))
+		So(Simplify(FilterOr(FilterTrue(), FilterSelector("a", "x"))), ShouldResemble, FilterTrue())
+		So(Simplify(FilterNot(FilterAnd(FilterTrue(), FilterTrue()))), ShouldResemble, FilterFalse())
+
+		Convey("multi-value dimensions", func() {
+			// A row with the values x and y matches both terms.
+			So(simplify(`a = 'x' AND a = 'y'`), ShouldEqual, `a = 'x' AND a = 'y'`)
+			So(simplify(`a IN ('x', 'y') AND a IN ('y', 'w') AND a = 'x'`), ShouldEqual, `a IN ('x', 'y') AND a IN ('y', 'w') AND a = 'x'`)
+			So(simplify(`n > 10 AND n < 9`), ShouldEqual, `n > 10 AND n < 9`)
+			So(simplify(`s IN ('a', 'b') AND s > 'a'`), ShouldEqual, `s IN ('a', 'b') AND s > 'a'`)
+			So(simplify(`a = 'x' AND NOT a = 'x'`), ShouldEqual, "FALSE")
+		})
+
+		Convey("bounds", func() {
+			So(simplify(`n >= 1 AND n < 10 AND n > 2 AND n <= 20`, SingleValued(true)), ShouldEqual, `n > 2 AND n < 10`)
+			So(simplify(`n > 10 AND n < 9`, SingleValued(true)), ShouldEqual, "FALSE")
+			So(simplify(`n >= 10 AND n < 10`, SingleValued(true)), ShouldEqual, "FALSE")
+			So(simplify(`n BETWEEN 10 AND 10`, SingleValued(true)), ShouldEqual, `n BETWEEN 10 AND 10`)
+			// The lexicographic and alphaNumeric bounds are kept apart.
+			So(simplify(`n >= 10 AND n < '9'`, SingleValued(true)), ShouldEqual, `n >= 10 AND n < '9'`)
+			So(simplify(`s IN ('a', 'b', 'c') AND s > 'a'`, SingleValued(true)), ShouldEqual, `s IN ('b', 'c')`)
+			So(simplify(`s = 'a' AND s > 'a'`, SingleValued(true)), ShouldEqual, "FALSE")
+		})
+
+		Convey("untouched", func() {
+			f := FilterAnd(FilterAnd(FilterSelector("a", "x"), FilterSelector("b", "y")), FilterSelector("c", "z"))
+			So(Simplify(f), ShouldResemble, FilterAnd(FilterSelector("a", "x"), FilterSelector("b", "y"), FilterSelector("c", "z")))
+			So(len(f.Fields), ShouldEqual, 2)
+
+			ex := FilterExtraction("a", "x", DimExFnPartial("^x"))
+			So(Simplify(FilterAnd(ex, FilterSelector("a", "y"))), ShouldResemble, FilterAnd(ex, FilterSelector("a", "y")))
+		})
+	})
+}
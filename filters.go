@@ -1,5 +1,11 @@
 package godruid
 
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
 type Filter struct {
 	Type         string           `json:"type"`
 	Dimension    string           `json:"dimension,omitempty"`
@@ -16,6 +22,22 @@ type Filter struct {
 	AlphaNumeric *AlphaNumeric    `json:"alphaNumeric,omitempty"`
 	LowerStrict  *LowerStrict     `json:"lowerStrict,omitempty"`
 	UpperStrict  *UpperStrict     `json:"upperStrict,omitempty"`
+	Ordering     BoundOrdering    `json:"ordering,omitempty"`
+	Escape       string           `json:"escape,omitempty"`
+	Intervals    IntervalList     `json:"intervals,omitempty"`
+	Dimensions   []DimSpec        `json:"dimensions,omitempty"`
+	Bound        *SpatialBound    `json:"bound,omitempty"`
+}
+
+// SpatialBound is the shape matched by a spatial filter.
+type SpatialBound struct {
+	Type      string    `json:"type"`
+	MinCoords []float64 `json:"minCoords,omitempty"`
+	MaxCoords []float64 `json:"maxCoords,omitempty"`
+	Coords    []float64 `json:"coords,omitempty"`
+	Radius    float64   `json:"radius,omitempty"`
+	Abscissa  []float64 `json:"abscissa,omitempty"`
+	Ordinate  []float64 `json:"ordinate,omitempty"`
 }
 
 // ---------------------------------
@@ -46,32 +68,64 @@ type UpperStrict bool
 
 func (b UpperStrict) apply(c *Filter) { c.UpperStrict = &b }
 
+// BoundOrdering is the ordering in which a bound filter compares the values.
+type BoundOrdering string
+
+const (
+	BoundLexicographic BoundOrdering = "lexicographic"
+	BoundAlphanumeric  BoundOrdering = "alphanumeric"
+	BoundNumeric       BoundOrdering = "numeric"
+	BoundStrlen        BoundOrdering = "strlen"
+	BoundVersion       BoundOrdering = "version"
+)
+
+func (o BoundOrdering) apply(c *Filter) { c.Ordering = o }
+
+type Escape string
+
+func (s Escape) apply(c *Filter) { c.Escape = string(s) }
+
+// An extraction function is an option of the filters which accept one.
+func (fn *DimExtractionFn) apply(c *Filter) { c.ExtractionFn = fn }
+
 // ---------------------------------
 // Constructors
 // ---------------------------------
 
-func FilterSelector(dimension string, value interface{}) *Filter {
-	return &Filter{
+func FilterSelector(dimension string, value interface{}, options ...FilterOption) *Filter {
+	filt := &Filter{
 		Type:      "selector",
 		Dimension: dimension,
 		Value:     value,
 	}
+	for _, opt := range options {
+		opt.apply(filt)
+	}
+	return filt
 }
 
-func FilterRegex(dimension, pattern string) *Filter {
-	return &Filter{
+func FilterRegex(dimension, pattern string, options ...FilterOption) *Filter {
+	filt := &Filter{
 		Type:      "regex",
 		Dimension: dimension,
 		Pattern:   pattern,
 	}
+	for _, opt := range options {
+		opt.apply(filt)
+	}
+	return filt
 }
 
-func FilterJavaScript(dimension, function string) *Filter {
-	return &Filter{
+func FilterJavaScript(dimension, function string, options ...FilterOption) *Filter {
+	filt := &Filter{
 		Type:      "javascript",
 		Dimension: dimension,
 		Function:  function,
 	}
+	for _, opt := range options {
+		opt.apply(filt)
+	}
+	return filt
 }
 
 func FilterAnd(filters ...*Filter) *Filter {
@@ -98,20 +152,33 @@ func FilterExtraction(dimension, value string, extractionFn *DimExtractionFn) *F
 	}
 }
 
-func FilterSearch(dimension string, query *QuerySearch) *Filter {
-	return &Filter{
+func FilterSearch(dimension string, query *QuerySearch, options ...FilterOption) *Filter {
+	filt := &Filter{
 		Type:      "search",
 		Dimension: dimension,
 		Query:     query,
 	}
+	for _, opt := range options {
+		opt.apply(filt)
+	}
+	return filt
 }
 
 func FilterIn(dimension string, values ...string) *Filter {
-	return &Filter{
+	return FilterInValues(dimension, values)
+}
+
+// FilterInValues is FilterIn with options, like an extraction function applied before the values are compared.
+func FilterInValues(dimension string, values []string, options ...FilterOption) *Filter {
+	filt := &Filter{
 		Type:      "in",
 		Dimension: dimension,
 		Values:    values,
 	}
+	for _, opt := range options {
+		opt.apply(filt)
+	}
+	return filt
 }
 
 func FilterBound(dimension string, options ...FilterOption) *Filter {
@@ -125,6 +192,142 @@ func FilterBound(dimension string, options ...FilterOption) *Filter {
 	return filt
 }
 
+// FilterFloatRange matches the numeric values between lower and upper, both included by default,
+// a side is unbounded when infinite.
+func FilterFloatRange(dimension string, lower, upper float64, options ...FilterOption) *Filter {
+	filt := &Filter{
+		Type:      "bound",
+		Dimension: dimension,
+		Ordering:  BoundNumeric,
+	}
+	if !math.IsInf(lower, -1) {
+		filt.Lower = strconv.FormatFloat(lower, 'f', -1, 64)
+	}
+	if !math.IsInf(upper, 1) {
+		filt.Upper = strconv.FormatFloat(upper, 'f', -1, 64)
+	}
+	for _, opt := range options {
+		opt.apply(filt)
+	}
+	return filt
+}
+
+// FilterIntRange matches the numeric values between lower and upper, both included by default,
+// a side is unbounded when math.MinInt64 or math.MaxInt64.
+func FilterIntRange(dimension string, lower, upper int64, options ...FilterOption) *Filter {
+	filt := &Filter{
+		Type:      "bound",
+		Dimension: dimension,
+		Ordering:  BoundNumeric,
+	}
+	if lower != math.MinInt64 {
+		filt.Lower = strconv.FormatInt(lower, 10)
+	}
+	if upper != math.MaxInt64 {
+		filt.Upper = strconv.FormatInt(upper, 10)
+	}
+	for _, opt := range options {
+		opt.apply(filt)
+	}
+	return filt
+}
+
+// FilterTimeRange matches the rows whose "__time" is between start, included, and end, excluded,
+// like an interval unless the options say otherwise. A side is unbounded when its time is zero.
+func FilterTimeRange(start, end time.Time, options ...FilterOption) *Filter {
+	filt := &Filter{
+		Type:      "bound",
+		Dimension: "__time",
+		Ordering:  BoundNumeric,
+	}
+	if !start.IsZero() {
+		filt.Lower = strconv.FormatInt(start.UnixMilli(), 10)
+	}
+	if !end.IsZero() {
+		filt.Upper = strconv.FormatInt(end.UnixMilli(), 10)
+		UpperStrict(true).apply(filt)
+	}
+	for _, opt := range options {
+		opt.apply(filt)
+	}
+	return filt
+}
+
+func FilterLike(dimension, pattern string, options ...FilterOption) *Filter {
+	filt := &Filter{
+		Type:      "like",
+		Dimension: dimension,
+		Pattern:   pattern,
+	}
+	for _, opt := range options {
+		opt.apply(filt)
+	}
+	return filt
+}
+
+// FilterInterval matches the rows whose dimension, usually "__time", is in one of the intervals.
+func FilterInterval(dimension string, intervals []string, options ...FilterOption) *Filter {
+	filt := &Filter{
+		Type:      "interval",
+		Dimension: dimension,
+		Intervals: intervals,
+	}
+	for _, opt := range options {
+		opt.apply(filt)
+	}
+	return filt
+}
+
+// FilterColumnComparison matches the rows where the dimensions have the same value.
+func FilterColumnComparison(dimensions ...DimSpec) *Filter {
+	return &Filter{
+		Type:       "columnComparison",
+		Dimensions: dimensions,
+	}
+}
+
+// FilterTrue matches every row.
+func FilterTrue() *Filter {
+	return &Filter{Type: "true"}
+}
+
+// FilterFalse matches no row.
+func FilterFalse() *Filter {
+	return &Filter{Type: "false"}
+}
+
+func FilterSpatial(dimension string, bound *SpatialBound) *Filter {
+	return &Filter{
+		Type:      "spatial",
+		Dimension: dimension,
+		Bound:     bound,
+	}
+}
+
+func SpatialRectangular(minCoords, maxCoords []float64) *SpatialBound {
+	return &SpatialBound{
+		Type:      "rectangular",
+		MinCoords: minCoords,
+		MaxCoords: maxCoords,
+	}
+}
+
+func SpatialRadius(coords []float64, radius float64) *SpatialBound {
+	return &SpatialBound{
+		Type:   "radius",
+		Coords: coords,
+		Radius: radius,
+	}
+}
+
+func SpatialPolygon(abscissa, ordinate []float64) *SpatialBound {
+	return &SpatialBound{
+		Type:     "polygon",
+		Abscissa: abscissa,
+		Ordinate: ordinate,
+	}
+}
+
 // ---------------------------------
 // Helpers
 // ---------------------------------
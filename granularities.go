@@ -1,5 +1,13 @@
 package godruid
 
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
 type Granularity interface{}
 
 type SimpleGran string
@@ -35,11 +43,20 @@ func (s TimeZone) apply(c *ComplexGran) { c.TimeZone = string(s) }
 const (
 	GranAll        SimpleGran = "all"
 	GranNone       SimpleGran = "none"
+	GranSecond     SimpleGran = "second"
 	GranMinute     SimpleGran = "minute"
+	GranFiveMin    SimpleGran = "five_minute"
+	GranTenMin     SimpleGran = "ten_minute"
 	GranFifteenMin SimpleGran = "fifteen_minute"
 	GranThirtyMin  SimpleGran = "thirty_minute"
 	GranHour       SimpleGran = "hour"
+	GranSixHour    SimpleGran = "six_hour"
+	GranEightHour  SimpleGran = "eight_hour"
 	GranDay        SimpleGran = "day"
+	GranWeek       SimpleGran = "week"
+	GranMonth      SimpleGran = "month"
+	GranQuarter    SimpleGran = "quarter"
+	GranYear       SimpleGran = "year"
 )
 
 func GranDuration(duration int, options ...GranOption) Granularity {
@@ -57,3 +74,433 @@ func GranPeriod(period string, options ...GranOption) Granularity {
 	}
 	return gran
 }
+
+// ---------------------------------
+// Validation
+// ---------------------------------
+
+// Validate checks the granularity the way Druid would before running the query.
+func (g ComplexGran) Validate() error {
+	_, err := granSpecOf(g)
+	return err
+}
+
+// ValidateGranularity checks a SimpleGran, a ComplexGran or a plain granularity string.
+func ValidateGranularity(g Granularity) error {
+	_, err := granSpecOf(g)
+	return err
+}
+
+// ---------------------------------
+// Bucketing
+// ---------------------------------
+
+// BucketStart returns the start of the bucket of the granularity which t falls in.
+// The buckets of the period granularities follow the calendar of their TimeZone, so they are
+// DST aware: a "P1D" bucket in "America/New_York" lasts 23 or 25 hours on the days the clocks change.
+// With GranAll and GranNone t is returned as is.
+func BucketStart(g Granularity, t time.Time) (time.Time, error) {
+	spec, err := granSpecOf(g)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return spec.truncate(t), nil
+}
+
+// BucketOf returns the bucket of the granularity which t falls in.
+// The bucket of GranNone lasts one millisecond, GranAll has no bucket.
+func BucketOf(g Granularity, t time.Time) (Interval, error) {
+	spec, err := granSpecOf(g)
+	if err != nil {
+		return Interval{}, err
+	}
+	if spec.all {
+		return Interval{}, fmt.Errorf("granularity all has no bucket")
+	}
+	start := spec.truncate(t)
+	return Interval{Start: start, End: spec.next(start)}, nil
+}
+
+// ---------------------------------
+// Comparison
+// ---------------------------------
+
+// NormalGran is the normalized form of any Granularity, which could be compared with the others.
+type NormalGran struct {
+	spec granSpec
+}
+
+func NormalizeGran(g Granularity) (NormalGran, error) {
+	spec, err := granSpecOf(g)
+	return NormalGran{spec: spec}, err
+}
+
+func (g NormalGran) IsAll() bool  { return g.spec.all }
+func (g NormalGran) IsNone() bool { return g.spec.none }
+
+// Duration returns the nominal length of the buckets, counting a year as 365 days and a month as 30 days.
+// It's the length of a millisecond for GranNone and the longest duration for GranAll.
+func (g NormalGran) Duration() time.Duration {
+	switch {
+	case g.spec.all:
+		return time.Duration(math.MaxInt64)
+	case g.spec.duration > 0:
+		return g.spec.duration
+	}
+	p := g.spec.period
+	return time.Duration(p.Years*365+p.Months*30+p.Weeks*7+p.Days)*24*time.Hour + p.timePart()
+}
+
+// FinerThan reports whether the buckets of g are shorter than the ones of o.
+func (g NormalGran) FinerThan(o NormalGran) bool {
+	return g.Duration() < o.Duration()
+}
+
+// Equal reports whether g and o produce the same buckets.
+func (g NormalGran) Equal(o NormalGran) bool {
+	a, b := g.spec, o.spec
+	return a.all == b.all && a.none == b.none && a.period == b.period && a.duration == b.duration &&
+		a.hasOrigin == b.hasOrigin && (!a.hasOrigin || a.origin.Equal(b.origin)) && a.loc.String() == b.loc.String()
+}
+
+// Bucket returns the bucket which t falls in, the zero Interval for GranAll.
+func (g NormalGran) Bucket(t time.Time) Interval {
+	if g.spec.all {
+		return Interval{}
+	}
+	start := g.spec.truncate(t)
+	return Interval{Start: start, End: g.spec.next(start)}
+}
+
+// Buckets returns the buckets overlapping iv, in the order Druid returns them.
+// GranAll produces one bucket, iv itself.
+func (g NormalGran) Buckets(iv Interval) []Interval {
+	if iv.IsEmpty() {
+		return nil
+	}
+	if g.spec.all {
+		return []Interval{iv}
+	}
+	var buckets []Interval
+	for start := g.spec.truncate(iv.Start); start.Before(iv.End); {
+		next := g.spec.next(start)
+		buckets = append(buckets, Interval{Start: start, End: next})
+		start = next
+	}
+	return buckets
+}
+
+// CountBuckets returns the number of buckets overlapping iv, without listing them for the fixed length buckets.
+func (g NormalGran) CountBuckets(iv Interval) int {
+	if iv.IsEmpty() {
+		return 0
+	}
+	if g.spec.all {
+		return 1
+	}
+	d := g.spec.duration
+	if d == 0 {
+		d, _ = g.spec.period.fixed()
+	}
+	if d > 0 {
+		start := g.spec.truncate(iv.Start)
+		return int((iv.End.Sub(start) + d - 1) / d)
+	}
+	n := 0
+	for start := g.spec.truncate(iv.Start); start.Before(iv.End); start = g.spec.next(start) {
+		n++
+	}
+	return n
+}
+
+func (g NormalGran) String() string {
+	switch {
+	case g.spec.all:
+		return string(GranAll)
+	case g.spec.none:
+		return string(GranNone)
+	}
+	var s string
+	if g.spec.duration > 0 {
+		s = g.spec.duration.String()
+	} else {
+		s = g.spec.period.String()
+	}
+	if g.spec.hasOrigin {
+		s += "@" + g.spec.origin.Format(IntervalTimeFormat)
+	}
+	if g.spec.loc != time.UTC {
+		s += " " + g.spec.loc.String()
+	}
+	return s
+}
+
+// ---------------------------------
+// Helpers
+// ---------------------------------
+
+// granSpec is the normalized form of a Granularity used for the bucketing math.
+type granSpec struct {
+	all, none bool
+	period    Period
+	duration  time.Duration // set for the duration granularities.
+	origin    time.Time
+	hasOrigin bool
+	loc       *time.Location
+}
+
+var simpleGranPeriods = map[SimpleGran]Period{
+	GranSecond:     {Seconds: 1},
+	GranMinute:     {Minutes: 1},
+	GranFiveMin:    {Minutes: 5},
+	GranTenMin:     {Minutes: 10},
+	GranFifteenMin: {Minutes: 15},
+	GranThirtyMin:  {Minutes: 30},
+	GranHour:       {Hours: 1},
+	GranSixHour:    {Hours: 6},
+	GranEightHour:  {Hours: 8},
+	GranDay:        {Days: 1},
+	GranWeek:       {Weeks: 1},
+	GranMonth:      {Months: 1},
+	GranQuarter:    {Months: 3},
+	GranYear:       {Years: 1},
+}
+
+func granSpecOf(g Granularity) (spec granSpec, err error) {
+	spec.loc = time.UTC
+	switch gran := g.(type) {
+	case string:
+		return granSpecOf(SimpleGran(gran))
+	case SimpleGran:
+		switch gran {
+		case GranAll:
+			spec.all = true
+			return
+		case GranNone:
+			spec.none = true
+			spec.duration = time.Millisecond
+			spec.origin = time.Unix(0, 0).UTC()
+			return
+		}
+		p, ok := simpleGranPeriods[gran]
+		if !ok {
+			return spec, fmt.Errorf("unknown granularity %q", gran)
+		}
+		spec.period = p
+		return
+	case *ComplexGran:
+		return granSpecOf(*gran)
+	case ComplexGran:
+		if gran.TimeZone != "" {
+			if spec.loc, err = time.LoadLocation(gran.TimeZone); err != nil {
+				return spec, fmt.Errorf("invalid granularity time zone %q", gran.TimeZone)
+			}
+		}
+		spec.origin = time.Unix(0, 0).UTC()
+		if gran.Origin != "" {
+			if spec.origin, err = parseIntervalTime(gran.Origin); err != nil {
+				return spec, fmt.Errorf("invalid granularity origin %q", gran.Origin)
+			}
+			spec.hasOrigin = true
+		}
+		switch gran.Type {
+		case "duration":
+			if gran.Period != "" || gran.TimeZone != "" {
+				return spec, fmt.Errorf("duration granularity takes no period nor time zone")
+			}
+			if gran.Duration <= 0 {
+				return spec, fmt.Errorf("invalid granularity duration %d", gran.Duration)
+			}
+			spec.duration = time.Duration(gran.Duration) * time.Millisecond
+			return
+		case "period":
+			if gran.Duration != 0 {
+				return spec, fmt.Errorf("period granularity takes no duration")
+			}
+			spec.period, err = ParsePeriod(gran.Period)
+			return
+		}
+		return spec, fmt.Errorf("unknown granularity type %q", gran.Type)
+	}
+	return spec, fmt.Errorf("unsupported granularity %v", g)
+}
+
+// truncate returns the start of the bucket which t falls in, it follows PeriodGranularity of Druid.
+func (g granSpec) truncate(t time.Time) time.Time {
+	start, _ := g.bucket(t)
+	return start
+}
+
+// next returns the start of the bucket following the one starting at bucketStart.
+func (g granSpec) next(bucketStart time.Time) time.Time {
+	_, next := g.bucket(bucketStart)
+	return next
+}
+
+// bucket returns the start of the bucket which t falls in and the start of the following one.
+// The calendar buckets are counted from the origin rather than from each other, so that the month
+// buckets of an origin on the 31st start on the last day of the shorter months and on the 31st again after.
+func (g granSpec) bucket(t time.Time) (time.Time, time.Time) {
+	if g.all {
+		return t, t
+	}
+	if g.duration > 0 {
+		start := floorDuration(t, g.origin, g.duration)
+		return start, start.Add(g.duration)
+	}
+
+	t = t.In(g.loc)
+	// Without origin the buckets start from 1970-01-01T00:00:00 in the time zone.
+	origin := time.Date(1970, 1, 1, 0, 0, 0, 0, g.loc)
+	if g.hasOrigin {
+		origin = g.origin.In(g.loc)
+	}
+	p := g.period
+	if !p.isSingleField() {
+		if d, ok := p.fixed(); ok {
+			start := floorDuration(t, origin, d)
+			return start, start.Add(d)
+		}
+		// Compound calendar period, step from the origin.
+		n := 0
+		if t.Before(origin) {
+			for n = -1; p.AddTo(origin, n).After(t); n-- {
+			}
+		} else {
+			for ; !p.AddTo(origin, n+1).After(t); n++ {
+			}
+		}
+		return p.AddTo(origin, n), p.AddTo(origin, n+1)
+	}
+
+	count, unit := p.singleField()
+	if count == 1 && !g.hasOrigin {
+		start := roundFloor(t, unit)
+		return start, p.AddTo(start, 1)
+	}
+	n := fieldDifference(t, origin, unit)
+	n -= n % count
+	n /= count
+	if t.Before(p.AddTo(origin, n)) {
+		n--
+	}
+	return p.AddTo(origin, n), p.AddTo(origin, n+1)
+}
+
+func floorDuration(t, origin time.Time, d time.Duration) time.Time {
+	diff := t.Sub(origin)
+	n := diff / d
+	if diff%d < 0 {
+		n--
+	}
+	return origin.Add(n * d)
+}
+
+// roundFloor truncates t to the start of its unit in the location of t.
+// The date units go through the calendar, a midnight skipped by DST becomes the first instant of the day.
+// The time units are cut from the local clock keeping the offset of t, so the ambiguous hours
+// of the end of DST stay distinct.
+func roundFloor(t time.Time, unit byte) time.Time {
+	y, mo, d := t.Date()
+	_, mi, s := t.Clock()
+	loc := t.Location()
+	switch unit {
+	case 'Y':
+		return time.Date(y, 1, 1, 0, 0, 0, 0, loc)
+	case 'M':
+		return time.Date(y, mo, 1, 0, 0, 0, 0, loc)
+	case 'W':
+		wd := (int(t.Weekday()) + 6) % 7 // Monday is the first day of the week.
+		return time.Date(y, mo, d-wd, 0, 0, 0, 0, loc)
+	case 'D':
+		return time.Date(y, mo, d, 0, 0, 0, 0, loc)
+	}
+	frac := time.Duration(t.Nanosecond())
+	switch unit {
+	case 'h':
+		return t.Add(-(time.Duration(mi)*time.Minute + time.Duration(s)*time.Second + frac))
+	case 'm':
+		return t.Add(-(time.Duration(s)*time.Second + frac))
+	case 's':
+		return t.Add(-frac)
+	}
+	return t.Add(-(frac % time.Millisecond))
+}
+
+// fieldDifference returns the number of whole units between origin and t, negative if t is before origin.
+func fieldDifference(t, origin time.Time, unit byte) int {
+	switch unit {
+	case 'Y', 'M':
+		var n int
+		add := func(n int) time.Time { return addMonths(origin, n) }
+		if unit == 'Y' {
+			n = t.Year() - origin.Year()
+			add = func(n int) time.Time { return addMonths(origin, 12*n) }
+		} else {
+			n = (t.Year()-origin.Year())*12 + int(t.Month()-origin.Month())
+		}
+		if n > 0 && add(n).After(t) {
+			n--
+		} else if n < 0 && add(n).Before(t) {
+			n++
+		}
+		return n
+	case 'W', 'D':
+		days := calendarDays(origin, t)
+		if days > 0 && origin.AddDate(0, 0, days).After(t) {
+			days--
+		} else if days < 0 && origin.AddDate(0, 0, days).Before(t) {
+			days++
+		}
+		if unit == 'W' {
+			return days / 7
+		}
+		return days
+	}
+	var d time.Duration
+	switch unit {
+	case 'h':
+		d = time.Hour
+	case 'm':
+		d = time.Minute
+	case 's':
+		d = time.Second
+	default:
+		d = time.Millisecond
+	}
+	return int(t.Sub(origin) / d)
+}
+
+// calendarDays returns the number of calendar days from the date of a to the date of b.
+func calendarDays(a, b time.Time) int {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return int(time.Date(by, bm, bd, 0, 0, 0, 0, time.UTC).Sub(time.Date(ay, am, ad, 0, 0, 0, 0, time.UTC)) / (24 * time.Hour))
+}
+
+// ---------------------------------
+// Decoding
+// ---------------------------------
+
+// decodeGranularity decodes a granularity returned by Druid, like the queryGranularity of segmentMetadata,
+// into a SimpleGran, including {"type": "none"} and {"type": "all"}, or a ComplexGran.
+func decodeGranularity(data []byte) (Granularity, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var gran interface{}
+	if err := json.Unmarshal(data, &gran); err != nil || gran == nil {
+		return nil, err
+	}
+	if name, ok := gran.(string); ok {
+		return SimpleGran(strings.ToLower(name)), nil
+	}
+	var complexGran ComplexGran
+	if err := json.Unmarshal(data, &complexGran); err != nil {
+		return nil, fmt.Errorf("invalid granularity %s: %v", data, err)
+	}
+	if complexGran.Type == string(GranNone) || complexGran.Type == string(GranAll) {
+		return SimpleGran(complexGran.Type), nil
+	}
+	return complexGran, nil
+}
@@ -0,0 +1,120 @@
+package godruid
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParsePeriod(t *testing.T) {
+	Convey("TestParsePeriod", t, func() {
+		p, err := ParsePeriod("P1Y2M3W4DT5H6M7.5S")
+		So(err, ShouldBeNil)
+		So(p, ShouldResemble, Period{Years: 1, Months: 2, Weeks: 3, Days: 4, Hours: 5, Minutes: 6, Seconds: 7, Millis: 500})
+		So(p.String(), ShouldEqual, "P1Y2M3W4DT5H6M7.500S")
+
+		p, err = ParsePeriod("PT15M")
+		So(err, ShouldBeNil)
+		So(p, ShouldResemble, Period{Minutes: 15})
+
+		for _, s := range []string{"", "1D", "P", "PT", "P1DT", "P1", "P1H", "PT1D", "P1D1Y", "P1.5D", "P0D", "P1X"} {
+			_, err = ParsePeriod(s)
+			So(err, ShouldNotBeNil)
+		}
+		_, err = ParsePeriod("P1D1Y")
+		So(err.(*PeriodError).Pos, ShouldEqual, 4)
+
+		Convey("month end", func() {
+			date := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 10, 0, 0, 0, time.UTC) }
+			month := Period{Months: 1}
+			So(month.AddTo(date(2016, 1, 31), 1), ShouldResemble, date(2016, 2, 29))
+			So(month.AddTo(date(2015, 1, 31), 1), ShouldResemble, date(2015, 2, 28))
+			So(month.AddTo(date(2016, 3, 31), -1), ShouldResemble, date(2016, 2, 29))
+			So(month.AddTo(date(2015, 3, 31), -1), ShouldResemble, date(2015, 2, 28))
+			So(month.AddTo(date(2016, 1, 31), 2), ShouldResemble, date(2016, 3, 31))
+			So(Period{Years: 1}.AddTo(date(2016, 2, 29), 1), ShouldResemble, date(2017, 2, 28))
+			So(Period{Months: 1, Days: 1}.AddTo(date(2016, 1, 31), 1), ShouldResemble, date(2016, 3, 1))
+		})
+
+		So(GranPeriod("P1M").(ComplexGran).Validate(), ShouldBeNil)
+		So(GranPeriod("1M").(ComplexGran).Validate(), ShouldNotBeNil)
+		So(GranPeriod("P1D", TimeZone("Mars/Olympus")).(ComplexGran).Validate(), ShouldNotBeNil)
+		So(GranDuration(0).(ComplexGran).Validate(), ShouldNotBeNil)
+		So(ValidateGranularity(GranHour), ShouldBeNil)
+		So(ValidateGranularity(SimpleGran("fortnight")), ShouldNotBeNil)
+	})
+}
+
+func TestBuckets(t *testing.T) {
+	Convey("TestBuckets", t, func() {
+		ts := time.Date(2016, 5, 17, 13, 45, 0, 0, time.UTC)
+		bucket := func(g Granularity) string {
+			iv, err := BucketOf(g, ts)
+			So(err, ShouldBeNil)
+			return iv.String()
+		}
+		So(bucket(GranFifteenMin), ShouldEqual, "2016-05-17T13:45:00.000Z/2016-05-17T14:00:00.000Z")
+		So(bucket(GranPeriod("P3M")), ShouldEqual, "2016-04-01T00:00:00.000Z/2016-07-01T00:00:00.000Z")
+		So(bucket(GranPeriod("P1W")), ShouldEqual, "2016-05-16T00:00:00.000Z/2016-05-23T00:00:00.000Z")
+		So(bucket(GranPeriod("P2D", Origin("2016-05-02T00:00:00Z"))), ShouldEqual, "2016-05-16T00:00:00.000Z/2016-05-18T00:00:00.000Z")
+		So(bucket(GranDuration(7200000)), ShouldEqual, "2016-05-17T12:00:00.000Z/2016-05-17T14:00:00.000Z")
+		So(bucket(GranPeriod("P1D", TimeZone("Asia/Kolkata"))), ShouldEqual, "2016-05-17T00:00:00.000+05:30/2016-05-18T00:00:00.000+05:30")
+
+		// The month buckets from the 31st start on the last day of the shorter months.
+		monthly := GranPeriod("P1M", Origin("2016-01-31T00:00:00Z"))
+		for _, c := range []struct {
+			t      time.Time
+			bucket string
+		}{
+			{time.Date(2016, 2, 29, 12, 0, 0, 0, time.UTC), "2016-02-29T00:00:00.000Z/2016-03-31T00:00:00.000Z"},
+			{time.Date(2016, 3, 30, 0, 0, 0, 0, time.UTC), "2016-02-29T00:00:00.000Z/2016-03-31T00:00:00.000Z"},
+			{time.Date(2016, 3, 31, 0, 0, 0, 0, time.UTC), "2016-03-31T00:00:00.000Z/2016-04-30T00:00:00.000Z"},
+			{time.Date(2016, 1, 15, 0, 0, 0, 0, time.UTC), "2015-12-31T00:00:00.000Z/2016-01-31T00:00:00.000Z"},
+		} {
+			iv, err := BucketOf(monthly, c.t)
+			So(err, ShouldBeNil)
+			So(iv.String(), ShouldEqual, c.bucket)
+		}
+
+		Convey("DST", func() {
+			ny, _ := time.LoadLocation("America/New_York")
+			day := GranPeriod("P1D", TimeZone("America/New_York"))
+			iv, err := BucketOf(day, time.Date(2016, 3, 13, 12, 0, 0, 0, ny))
+			So(err, ShouldBeNil)
+			So(iv.Duration(), ShouldEqual, 23*time.Hour)
+			iv, _ = BucketOf(day, time.Date(2016, 11, 6, 12, 0, 0, 0, ny))
+			So(iv.Duration(), ShouldEqual, 25*time.Hour)
+
+			// 01:30 happens twice on 2016-11-06, once in EDT and once in EST.
+			hour := GranPeriod("PT1H", TimeZone("America/New_York"))
+			second := time.Date(2016, 11, 6, 6, 30, 0, 0, time.UTC)
+			iv, _ = BucketOf(hour, second)
+			So(iv.Contains(second), ShouldBeTrue)
+			So(iv.Duration(), ShouldEqual, time.Hour)
+		})
+	})
+}
+
+func TestNormalGran(t *testing.T) {
+	Convey("TestNormalGran", t, func() {
+		hour, err := NormalizeGran(GranHour)
+		So(err, ShouldBeNil)
+		pt1h, _ := NormalizeGran(GranPeriod("PT1H"))
+		quarter, _ := NormalizeGran(GranQuarter)
+		week, _ := NormalizeGran(GranWeek)
+		all, _ := NormalizeGran(GranAll)
+		So(hour.Equal(pt1h), ShouldBeTrue)
+		So(hour.Duration(), ShouldEqual, time.Hour)
+		So(hour.FinerThan(week), ShouldBeTrue)
+		So(quarter.FinerThan(week), ShouldBeFalse)
+		So(quarter.FinerThan(all), ShouldBeTrue)
+
+		iv, _ := ParseInterval("2016-01-15/2016-07-02")
+		So(quarter.CountBuckets(iv), ShouldEqual, 3)
+		So(quarter.Buckets(iv)[0].String(), ShouldEqual, "2016-01-01T00:00:00.000Z/2016-04-01T00:00:00.000Z")
+		So(hour.CountBuckets(iv), ShouldEqual, len(hour.Buckets(iv)))
+		So(all.CountBuckets(iv), ShouldEqual, 1)
+		So(week.Bucket(iv.Start).Start.Weekday(), ShouldEqual, time.Monday)
+	})
+}
@@ -1,5 +1,7 @@
 package godruid
 
+import "fmt"
+
 type Having struct {
 	Type        string      `json:"type"`
 	Aggregation string      `json:"aggregation,omitempty"`
@@ -7,6 +9,7 @@ type Having struct {
 	Dimension   string      `json:"dimension,omitempty"`
 	HavingSpec  *Having     `json:"havingSpec,omitempty"`
 	HavingSpecs []*Having   `json:"havingSpecs,omitempty"`
+	Filter      *Filter     `json:"filter,omitempty"`
 }
 
 // ---------------------------------
@@ -45,6 +48,24 @@ func HavingDimSelector(dimension string, value interface{}) *Having {
 	}
 }
 
+// HavingIsNull matches the rows where the dimension is null or empty.
+func HavingIsNull(dimension string) *Having {
+	return HavingDimSelector(dimension, nil)
+}
+
+// HavingNotNull matches the rows where the dimension has a value.
+func HavingNotNull(dimension string) *Having {
+	return HavingNot(HavingIsNull(dimension))
+}
+
+// HavingFilter matches the rows matched by the filter, which could also use the aggregations as columns.
+func HavingFilter(filter *Filter) *Having {
+	return &Having{
+		Type:   "filter",
+		Filter: filter,
+	}
+}
+
 func HavingAnd(havings ...*Having) *Having {
 	return joinHavings(havings, "and")
 }
@@ -60,6 +81,61 @@ func HavingNot(having *Having) *Having {
 	}
 }
 
+// ---------------------------------
+// Evaluation
+// ---------------------------------
+
+// Evaluate applies the having to a groupBy result row, like Event of GroupbyItem.
+// The comparisons are numeric, a row whose aggregation is missing or not a number doesn't match them.
+// A nil having matches every row.
+func (h *Having) Evaluate(row map[string]interface{}) (bool, error) {
+	if h == nil {
+		return true, nil
+	}
+	switch h.Type {
+	case "equalTo", "greaterThan", "lessThan":
+		value, ok := toFloat(h.Value)
+		if !ok {
+			return false, fmt.Errorf("%s having on %q with a non-numeric value %v", h.Type, h.Aggregation, h.Value)
+		}
+		metric, ok := toFloat(row[h.Aggregation])
+		if !ok {
+			return false, nil
+		}
+		switch h.Type {
+		case "equalTo":
+			return metric == value, nil
+		case "greaterThan":
+			return metric > value, nil
+		}
+		return metric < value, nil
+	case "dimSelector":
+		return FilterSelector(h.Dimension, h.Value).Match(row)
+	case "filter":
+		return h.Filter.Match(row)
+	case "and":
+		for _, spec := range h.HavingSpecs {
+			ok, err := spec.Evaluate(row)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	case "or":
+		for _, spec := range h.HavingSpecs {
+			ok, err := spec.Evaluate(row)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	case "not":
+		ok, err := h.HavingSpec.Evaluate(row)
+		return !ok && err == nil, err
+	}
+	return false, fmt.Errorf("can't evaluate having of type %q", h.Type)
+}
+
 // ---------------------------------
 // Helpers
 // ---------------------------------
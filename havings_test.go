@@ -0,0 +1,41 @@
+package godruid
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHavingEvaluate(t *testing.T) {
+	Convey("TestHavingEvaluate", t, func() {
+		row := map[string]interface{}{"os": "ios", "count": 12.0, "revenue": 3.5}
+		evaluate := func(h *Having) bool {
+			ok, err := h.Evaluate(row)
+			So(err, ShouldBeNil)
+			return ok
+		}
+
+		So(evaluate(nil), ShouldBeTrue)
+		So(evaluate(HavingEqualTo("count", 12)), ShouldBeTrue)
+		So(evaluate(HavingGreaterThan("revenue", 3.5)), ShouldBeFalse)
+		So(evaluate(HavingLessThan("revenue", 4)), ShouldBeTrue)
+		So(evaluate(HavingGreaterThan("missing", 0)), ShouldBeFalse)
+		So(evaluate(HavingDimSelector("os", "ios")), ShouldBeTrue)
+		So(evaluate(HavingAnd(HavingGreaterThan("count", 10), HavingNot(HavingDimSelector("os", "android")))), ShouldBeTrue)
+		So(evaluate(HavingOr(HavingGreaterThan("count", 100), HavingLessThan("count", 1))), ShouldBeFalse)
+		So(evaluate(HavingIsNull("browser")), ShouldBeTrue)
+		So(evaluate(HavingNotNull("os")), ShouldBeTrue)
+		So(evaluate(HavingFilter(FilterAnd(FilterSelector("os", "ios"), FilterFloatRange("count", 10, 20)))), ShouldBeTrue)
+
+		_, err := HavingEqualTo("count", "x").Evaluate(row)
+		So(err, ShouldNotBeNil)
+
+		b, err := json.Marshal(HavingFilter(FilterSelector("os", "ios")))
+		So(err, ShouldBeNil)
+		So(string(b), ShouldEqual, `{"type":"filter","filter":{"type":"selector","dimension":"os","value":"ios"}}`)
+		b, err = json.Marshal(HavingNotNull("os"))
+		So(err, ShouldBeNil)
+		So(string(b), ShouldEqual, `{"type":"not","havingSpec":{"type":"dimSelector","dimension":"os"}}`)
+	})
+}
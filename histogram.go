@@ -0,0 +1,98 @@
+package godruid
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Histogram is a finalized approximate histogram, or the result of the equalBuckets, buckets
+// and customBuckets post aggregations. Counts[i] is the count between Breaks[i] and Breaks[i+1].
+type Histogram struct {
+	Breaks []float64 `json:"breaks"`
+	Counts []float64 `json:"counts"`
+}
+
+// Quantiles is the result of the quantiles post aggregation.
+type Quantiles struct {
+	Probabilities []float64 `json:"probabilities"`
+	Quantiles     []float64 `json:"quantiles"`
+	Min           float64   `json:"min"`
+	Max           float64   `json:"max"`
+}
+
+// FixedBucketsHistogram is a finalized fixed buckets histogram.
+type FixedBucketsHistogram struct {
+	LowerLimit          float64   `json:"lowerLimit"`
+	UpperLimit          float64   `json:"upperLimit"`
+	NumBuckets          int       `json:"numBuckets"`
+	OutlierHandlingMode string    `json:"outlierHandlingMode"`
+	Histogram           []float64 `json:"histogram"`
+	Count               float64   `json:"count"`
+	Min                 float64   `json:"min"`
+	Max                 float64   `json:"max"`
+	LowerOutlierCount   float64   `json:"lowerOutlierCount"`
+	UpperOutlierCount   float64   `json:"upperOutlierCount"`
+	MissingValueCount   float64   `json:"missingValueCount"`
+}
+
+// DecodeHistogram decodes a histogram from a result value, like row["name"] of a groupBy event.
+func DecodeHistogram(v interface{}) (*Histogram, error) {
+	h := &Histogram{}
+	if err := decodeResultValue(v, h); err != nil {
+		return nil, err
+	}
+	if len(h.Counts) != 0 && len(h.Breaks) != len(h.Counts)+1 {
+		return nil, fmt.Errorf("histogram with %d breaks and %d counts", len(h.Breaks), len(h.Counts))
+	}
+	return h, nil
+}
+
+// DecodeQuantiles decodes the result of a quantiles post aggregation.
+func DecodeQuantiles(v interface{}) (*Quantiles, error) {
+	q := &Quantiles{}
+	if err := decodeResultValue(v, q); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// DecodeFixedBucketsHistogram decodes a finalized fixed buckets histogram.
+func DecodeFixedBucketsHistogram(v interface{}) (*FixedBucketsHistogram, error) {
+	h := &FixedBucketsHistogram{}
+	if err := decodeResultValue(v, h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Breaks returns the limits of the buckets, the bucket i goes from Breaks()[i] to Breaks()[i+1].
+func (h *FixedBucketsHistogram) Breaks() []float64 {
+	if h.NumBuckets <= 0 {
+		return nil
+	}
+	breaks := make([]float64, h.NumBuckets+1)
+	width := (h.UpperLimit - h.LowerLimit) / float64(h.NumBuckets)
+	for i := range breaks {
+		breaks[i] = h.LowerLimit + float64(i)*width
+	}
+	breaks[h.NumBuckets] = h.UpperLimit
+	return breaks
+}
+
+// decodeResultValue converts a value decoded in an interface{}, a map usually, into out.
+// A string value is taken as JSON, which is how some Druid versions serialize the complex results.
+func decodeResultValue(v, out interface{}) error {
+	if v == nil {
+		return fmt.Errorf("no result value")
+	}
+	var b []byte
+	if s, ok := v.(string); ok {
+		b = []byte(s)
+	} else {
+		var err error
+		if b, err = json.Marshal(v); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(b, out)
+}
@@ -0,0 +1,67 @@
+package godruid
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHistogram(t *testing.T) {
+	Convey("TestHistogram", t, func() {
+		Convey("specs", func() {
+			b, err := json.Marshal(AggApproxHistogramFold("latency", "latency_hist", Resolution(50), NumBuckets(7), LowerLimit(0), UpperLimit(1000)))
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, `{"type":"approxHistogramFold","name":"latency","fieldName":"latency_hist","resolution":50,"numBuckets":7,"lowerLimit":0,"upperLimit":1000}`)
+			b, err = json.Marshal(AggFixedBucketsHistogram("latency", "latency", 0, 100, 10, OutlierClip))
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, `{"type":"fixedBucketsHistogram","name":"latency","fieldName":"latency","numBuckets":10,"lowerLimit":0,"upperLimit":100,"outlierHandlingMode":"clip"}`)
+			So(AggApproxHistogram("latency", "latency").Type, ShouldEqual, "approxHistogram")
+
+			b, err = json.Marshal([]PostAggregation{
+				PostAggQuantile("p50", "latency", 0.5),
+				PostAggQuantiles("ps", "latency", 0.5, 0.9),
+				PostAggEqualBuckets("eq", "latency", 5),
+				PostAggBuckets("b", "latency", 10, 0),
+				PostAggCustomBuckets("c", "latency", 0, 10, 100),
+			})
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, `[{"type":"quantile","name":"p50","fieldName":"latency","probability":0.5},`+
+				`{"type":"quantiles","name":"ps","fieldName":"latency","probabilities":[0.5,0.9]},`+
+				`{"type":"equalBuckets","name":"eq","fieldName":"latency","numBuckets":5},`+
+				`{"type":"buckets","name":"b","fieldName":"latency","bucketSize":10},`+
+				`{"type":"customBuckets","name":"c","fieldName":"latency","breaks":[0,10,100]}]`)
+		})
+
+		Convey("decode", func() {
+			q := &QueryGroupBy{}
+			err := q.onResponse([]byte(`[{"version":"v1","timestamp":"2016-05-01T00:00:00.000Z","event":{
+				"hist":{"breaks":[0,10,100],"counts":[3,4]},
+				"ps":{"probabilities":[0.5,0.9],"quantiles":[8.5,71],"min":1,"max":99},
+				"fixed":{"lowerLimit":0,"upperLimit":10,"numBuckets":2,"histogram":[1,2],"count":3},
+				"bad":{"breaks":[0],"counts":[1,2]}}}]`))
+			So(err, ShouldBeNil)
+			event := q.QueryResult[0].Event
+
+			h, err := DecodeHistogram(event["hist"])
+			So(err, ShouldBeNil)
+			So(h, ShouldResemble, &Histogram{Breaks: []float64{0, 10, 100}, Counts: []float64{3, 4}})
+			ps, err := DecodeQuantiles(event["ps"])
+			So(err, ShouldBeNil)
+			So(ps.Quantiles, ShouldResemble, []float64{8.5, 71})
+			So(ps.Max, ShouldEqual, 99.0)
+			fixed, err := DecodeFixedBucketsHistogram(event["fixed"])
+			So(err, ShouldBeNil)
+			So(fixed.Histogram, ShouldResemble, []float64{1, 2})
+			So(fixed.Breaks(), ShouldResemble, []float64{0, 5, 10})
+
+			_, err = DecodeHistogram(event["bad"])
+			So(err, ShouldNotBeNil)
+			_, err = DecodeHistogram(event["missing"])
+			So(err, ShouldNotBeNil)
+			h, err = DecodeHistogram(`{"breaks":[1,2],"counts":[5]}`)
+			So(err, ShouldBeNil)
+			So(h.Counts, ShouldResemble, []float64{5})
+		})
+	})
+}
@@ -0,0 +1,303 @@
+package godruid
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// IntervalTimeFormat is the layout used to format the interval bounds.
+const IntervalTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+var intervalTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02T15",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// now is replaced in the tests.
+var now = time.Now
+
+// Interval is a half-open time range [Start, End).
+type Interval struct {
+	Start time.Time
+	End   time.Time
+}
+
+// ---------------------------------
+// Constructors
+// ---------------------------------
+
+func IntervalOf(start, end time.Time) Interval {
+	return Interval{Start: start, End: end}
+}
+
+// IntervalAfter returns the interval of the ISO-8601 period starting at start, like "2016-05-01/PT1H".
+func IntervalAfter(start time.Time, period string) (Interval, error) {
+	p, err := ParsePeriod(period)
+	if err != nil {
+		return Interval{}, err
+	}
+	return Interval{Start: start, End: p.AddTo(start, 1)}, nil
+}
+
+// IntervalBefore returns the interval of the ISO-8601 period ending at end, like "P1D/2016-05-01".
+func IntervalBefore(period string, end time.Time) (Interval, error) {
+	p, err := ParsePeriod(period)
+	if err != nil {
+		return Interval{}, err
+	}
+	return Interval{Start: p.AddTo(end, -1), End: end}, nil
+}
+
+// IntervalLast returns the interval of the ISO-8601 period ending now.
+func IntervalLast(period string) (Interval, error) {
+	return IntervalBefore(period, now().UTC())
+}
+
+// IntervalLastDays returns the last n whole days in UTC, today excluded.
+func IntervalLastDays(n int) Interval {
+	today := roundFloor(now().UTC(), 'D')
+	return Interval{Start: today.AddDate(0, 0, -n), End: today}
+}
+
+// IntervalToday returns the current day in loc, UTC if nil.
+func IntervalToday(loc *time.Location) Interval {
+	if loc == nil {
+		loc = time.UTC
+	}
+	today := roundFloor(now().In(loc), 'D')
+	return Interval{Start: today, End: today.AddDate(0, 0, 1)}
+}
+
+// ParseInterval parses the ISO-8601 intervals "start/end", "period/end" and "start/period".
+// The times without time zone are in UTC.
+func ParseInterval(s string) (Interval, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 {
+		return Interval{}, fmt.Errorf("invalid interval %q", s)
+	}
+	startIsPeriod := strings.HasPrefix(parts[0], "P")
+	endIsPeriod := strings.HasPrefix(parts[1], "P")
+	switch {
+	case startIsPeriod && endIsPeriod:
+		return Interval{}, fmt.Errorf("invalid interval %q", s)
+	case startIsPeriod:
+		end, err := parseIntervalTime(parts[1])
+		if err != nil {
+			return Interval{}, err
+		}
+		return IntervalBefore(parts[0], end)
+	case endIsPeriod:
+		start, err := parseIntervalTime(parts[0])
+		if err != nil {
+			return Interval{}, err
+		}
+		return IntervalAfter(start, parts[1])
+	}
+	start, err := parseIntervalTime(parts[0])
+	if err != nil {
+		return Interval{}, err
+	}
+	end, err := parseIntervalTime(parts[1])
+	if err != nil {
+		return Interval{}, err
+	}
+	if end.Before(start) {
+		return Interval{}, fmt.Errorf("interval %q ends before it starts", s)
+	}
+	return Interval{Start: start, End: end}, nil
+}
+
+// ParseIntervals parses the intervals of a query.
+func ParseIntervals(intervals []string) ([]Interval, error) {
+	ivs := make([]Interval, len(intervals))
+	for i, s := range intervals {
+		iv, err := ParseInterval(s)
+		if err != nil {
+			return nil, err
+		}
+		ivs[i] = iv
+	}
+	return ivs, nil
+}
+
+// IntervalList is the Intervals field of the queries, the ISO-8601 intervals as strings.
+// The []string literals still fit in it, Intervals builds it from typed intervals.
+type IntervalList []string
+
+// Parse parses the intervals of the list.
+func (l IntervalList) Parse() ([]Interval, error) {
+	return ParseIntervals(l)
+}
+
+// Intervals formats the intervals for the Intervals field of the queries.
+func Intervals(ivs ...Interval) IntervalList {
+	intervals := make(IntervalList, len(ivs))
+	for i, iv := range ivs {
+		intervals[i] = iv.String()
+	}
+	return intervals
+}
+
+// SetIntervals sets the Intervals field of a query to the intervals,
+// it fails for the kinds of query without intervals.
+func SetIntervals(query Query, ivs ...Interval) error {
+	intervals := Intervals(ivs...)
+	switch q := query.(type) {
+	case *QueryGroupBy:
+		q.Intervals = intervals
+	case *QuerySearch:
+		q.Intervals = intervals
+	case *QuerySegmentMetadata:
+		q.Intervals = intervals
+	case *QuerySelect:
+		q.Intervals = intervals
+	case *QueryTimeBoundary:
+		q.Intervals = intervals
+	case *QueryTimeseries:
+		q.Intervals = intervals
+	case *QueryTopN:
+		q.Intervals = intervals
+	default:
+		return fmt.Errorf("%T has no intervals", query)
+	}
+	return nil
+}
+
+// QueryIntervals parses the Intervals field of a query,
+// it fails for the kinds of query without intervals.
+func QueryIntervals(query Query) ([]Interval, error) {
+	intervals, ok := queryIntervals(query)
+	if q, isTimeBoundary := query.(*QueryTimeBoundary); isTimeBoundary {
+		intervals, ok = q.Intervals, true
+	}
+	if !ok {
+		return nil, fmt.Errorf("%T has no intervals", query)
+	}
+	return ParseIntervals(intervals)
+}
+
+func parseIntervalTime(s string) (time.Time, error) {
+	for _, layout := range intervalTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid interval time %q", s)
+}
+
+// ---------------------------------
+// Methods
+// ---------------------------------
+
+func (i Interval) String() string {
+	return i.Start.Format(IntervalTimeFormat) + "/" + i.End.Format(IntervalTimeFormat)
+}
+
+func (i Interval) MarshalText() ([]byte, error) {
+	return []byte(i.String()), nil
+}
+
+func (i *Interval) UnmarshalText(text []byte) (err error) {
+	*i, err = ParseInterval(string(text))
+	return
+}
+
+func (i Interval) Duration() time.Duration {
+	return i.End.Sub(i.Start)
+}
+
+func (i Interval) IsEmpty() bool {
+	return !i.Start.Before(i.End)
+}
+
+// Contains reports whether t is in [Start, End).
+func (i Interval) Contains(t time.Time) bool {
+	return !t.Before(i.Start) && t.Before(i.End)
+}
+
+// Overlaps reports whether the two intervals share some time.
+func (i Interval) Overlaps(o Interval) bool {
+	return i.Start.Before(o.End) && o.Start.Before(i.End)
+}
+
+// Intersect returns the common part of the two intervals, false if they don't overlap.
+func (i Interval) Intersect(o Interval) (Interval, bool) {
+	if !i.Overlaps(o) {
+		return Interval{}, false
+	}
+	res := i
+	if o.Start.After(res.Start) {
+		res.Start = o.Start
+	}
+	if o.End.Before(res.End) {
+		res.End = o.End
+	}
+	return res, true
+}
+
+// Union returns the interval covering both intervals, false if they neither overlap nor abut.
+func (i Interval) Union(o Interval) (Interval, bool) {
+	if i.Start.After(o.End) || o.Start.After(i.End) {
+		return Interval{}, false
+	}
+	res := i
+	if o.Start.Before(res.Start) {
+		res.Start = o.Start
+	}
+	if o.End.After(res.End) {
+		res.End = o.End
+	}
+	return res, true
+}
+
+// Shift returns the interval moved by n times the ISO-8601 period, n could be negative.
+func (i Interval) Shift(period string, n int) (Interval, error) {
+	p, err := ParsePeriod(period)
+	if err != nil {
+		return Interval{}, err
+	}
+	return Interval{Start: p.AddTo(i.Start, n), End: p.AddTo(i.End, n)}, nil
+}
+
+// Align widens the interval to the bucket boundaries of the granularity.
+func (i Interval) Align(gran Granularity) (Interval, error) {
+	g, err := granSpecOf(gran)
+	if err != nil {
+		return Interval{}, err
+	}
+	if g.all {
+		return i, nil
+	}
+	res := Interval{Start: g.truncate(i.Start), End: g.truncate(i.End)}
+	if res.End.Before(i.End) {
+		res.End = g.next(res.End)
+	}
+	return res, nil
+}
+
+// UnionIntervals sorts the intervals and merges the overlapping or abutting ones.
+func UnionIntervals(ivs ...Interval) []Interval {
+	sorted := make([]Interval, len(ivs))
+	copy(sorted, ivs)
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a].Start.Before(sorted[b].Start) })
+
+	var res []Interval
+	for _, iv := range sorted {
+		if n := len(res); n > 0 {
+			if u, ok := res[n-1].Union(iv); ok {
+				res[n-1] = u
+				continue
+			}
+		}
+		res = append(res, iv)
+	}
+	return res
+}
@@ -0,0 +1,82 @@
+package godruid
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestInterval(t *testing.T) {
+	Convey("TestInterval", t, func() {
+		iv, err := ParseInterval("2016-05-01T00:00/2016-05-01T01")
+		So(err, ShouldBeNil)
+		So(iv.String(), ShouldEqual, "2016-05-01T00:00:00.000Z/2016-05-01T01:00:00.000Z")
+
+		iv, err = ParseInterval("P1D/2016-05-01")
+		So(err, ShouldBeNil)
+		So(iv.String(), ShouldEqual, "2016-04-30T00:00:00.000Z/2016-05-01T00:00:00.000Z")
+
+		iv, err = ParseInterval("P1M/2016-03-31")
+		So(err, ShouldBeNil)
+		So(iv.String(), ShouldEqual, "2016-02-29T00:00:00.000Z/2016-03-31T00:00:00.000Z")
+		iv, _ = ParseInterval("2016-01-31/2016-02-01")
+		shifted, err := iv.Shift("P1M", 1)
+		So(err, ShouldBeNil)
+		So(shifted.String(), ShouldEqual, "2016-02-29T00:00:00.000Z/2016-03-01T00:00:00.000Z")
+
+		iv, err = ParseInterval("2016-05-01/PT1H")
+		So(err, ShouldBeNil)
+		So(iv.Duration(), ShouldEqual, time.Hour)
+
+		_, err = ParseInterval("2016-05-01")
+		So(err, ShouldNotBeNil)
+		_, err = ParseInterval("P1D/PT1H")
+		So(err, ShouldNotBeNil)
+
+		a, _ := ParseInterval("2016-05-01/2016-05-03")
+		b, _ := ParseInterval("2016-05-02/2016-05-05")
+		c, _ := ParseInterval("2016-05-05/2016-05-06")
+		So(a.Overlaps(b), ShouldBeTrue)
+		So(b.Overlaps(c), ShouldBeFalse)
+		So(UnionIntervals(c, a, b), ShouldResemble, []Interval{IntervalOf(a.Start, c.End)})
+
+		iv, _ = ParseInterval("2016-05-01T10:30/2016-05-03T01:00")
+		aligned, err := iv.Align(GranDay)
+		So(err, ShouldBeNil)
+		So(aligned.String(), ShouldEqual, "2016-05-01T00:00:00.000Z/2016-05-04T00:00:00.000Z")
+
+		now = func() time.Time { return time.Date(2016, 5, 8, 13, 0, 0, 0, time.UTC) }
+		defer func() { now = time.Now }()
+		So(IntervalLastDays(7).String(), ShouldEqual, "2016-05-01T00:00:00.000Z/2016-05-08T00:00:00.000Z")
+
+		raw, _ := json.Marshal(Intervals(a))
+		So(string(raw), ShouldEqual, `["2016-05-01T00:00:00.000Z/2016-05-03T00:00:00.000Z"]`)
+
+		q := &QueryTimeseries{DataSource: "ds", Granularity: GranAll}
+		So(SetIntervals(q, a, c), ShouldBeNil)
+		So(q.Intervals, ShouldResemble, IntervalList{"2016-05-01T00:00:00.000Z/2016-05-03T00:00:00.000Z", "2016-05-05T00:00:00.000Z/2016-05-06T00:00:00.000Z"})
+		ivs, err := QueryIntervals(q)
+		So(err, ShouldBeNil)
+		So(ivs, ShouldResemble, []Interval{a, c})
+
+		// The typed intervals are assigned directly, the string literals keep working.
+		ts := &QueryTimeseries{DataSource: "ds", Granularity: GranAll, Intervals: Intervals(a, c)}
+		ivs, err = ts.Intervals.Parse()
+		So(err, ShouldBeNil)
+		So(ivs, ShouldResemble, []Interval{a, c})
+		ts.Intervals = []string{"2016-05-01/2016-05-03"}
+		var plain []string = ts.Intervals
+		So(plain, ShouldResemble, []string{"2016-05-01/2016-05-03"})
+		ivs, err = ts.Intervals.Parse()
+		So(err, ShouldBeNil)
+		So(ivs, ShouldResemble, []Interval{a})
+		tb := &QueryTimeBoundary{DataSource: "ds"}
+		So(SetIntervals(tb, b), ShouldBeNil)
+		So(tb.Intervals, ShouldResemble, Intervals(b))
+		So(SetIntervals(&QueryDataSourceMetadata{}, a), ShouldNotBeNil)
+		_, err = QueryIntervals(&QueryDataSourceMetadata{})
+		So(err, ShouldNotBeNil)
+	})
+}
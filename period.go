@@ -0,0 +1,235 @@
+package godruid
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Period is an ISO-8601 period like "P1M", "PT15M" or "P1DT12H".
+// Only the seconds could have a fraction, which is kept as Millis.
+type Period struct {
+	Years, Months, Weeks, Days      int
+	Hours, Minutes, Seconds, Millis int
+}
+
+// PeriodError tells which part of a period string is invalid.
+type PeriodError struct {
+	Period string
+	Pos    int // Byte offset of the invalid part.
+	Msg    string
+}
+
+func (e *PeriodError) Error() string {
+	return fmt.Sprintf("invalid period %q at %d: %s", e.Period, e.Pos, e.Msg)
+}
+
+// The designators in the order they have to appear, 'T' excluded.
+const (
+	dateDesignators = "YMWD"
+	timeDesignators = "HMS"
+)
+
+// ParsePeriod parses an ISO-8601 period, the designators must appear in order and at most once.
+func ParsePeriod(s string) (p Period, err error) {
+	fail := func(pos int, msg string) (Period, error) {
+		return Period{}, &PeriodError{Period: s, Pos: pos, Msg: msg}
+	}
+	if !strings.HasPrefix(s, "P") {
+		return fail(0, "missing leading 'P'")
+	}
+	inTime := false
+	last := -1 // Index of the last designator seen in its section.
+	numStart := -1
+	fraction := false
+	fields := 0
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= '0' && c <= '9':
+			if numStart < 0 {
+				numStart = i
+			}
+			continue
+		case c == '.' || c == ',':
+			if numStart < 0 || fraction {
+				return fail(i, "misplaced decimal mark")
+			}
+			fraction = true
+			continue
+		case c == 'T':
+			if inTime || numStart >= 0 {
+				return fail(i, "misplaced 'T'")
+			}
+			inTime, last = true, -1
+			if i == len(s)-1 {
+				return fail(i, "no time field after 'T'")
+			}
+			continue
+		}
+
+		designators := dateDesignators
+		if inTime {
+			designators = timeDesignators
+		}
+		idx := strings.IndexByte(designators, c)
+		if idx < 0 {
+			return fail(i, fmt.Sprintf("unexpected %q", c))
+		}
+		if numStart < 0 {
+			return fail(i, fmt.Sprintf("missing number before %q", c))
+		}
+		if idx <= last {
+			return fail(i, fmt.Sprintf("%q out of order", c))
+		}
+		if fraction && !(inTime && c == 'S') {
+			return fail(numStart, "only the seconds could have a fraction")
+		}
+		num := strings.Replace(s[numStart:i], ",", ".", 1)
+		n, perr := strconv.ParseFloat(num, 64)
+		if perr != nil || n > 1<<31-1 {
+			return fail(numStart, "number out of range")
+		}
+		whole := int(n)
+		switch {
+		case !inTime && c == 'Y':
+			p.Years = whole
+		case !inTime && c == 'M':
+			p.Months = whole
+		case !inTime && c == 'W':
+			p.Weeks = whole
+		case !inTime && c == 'D':
+			p.Days = whole
+		case c == 'H':
+			p.Hours = whole
+		case c == 'M':
+			p.Minutes = whole
+		case c == 'S':
+			p.Seconds = whole
+			p.Millis = int((n-float64(whole))*1000 + 0.5)
+		}
+		last, numStart, fraction = idx, -1, false
+		fields++
+	}
+	if numStart >= 0 {
+		return fail(numStart, "missing designator after number")
+	}
+	if fields == 0 {
+		return fail(len(s), "no field")
+	}
+	if p.IsZero() {
+		return fail(0, "zero period")
+	}
+	return p, nil
+}
+
+func (p Period) IsZero() bool {
+	return p == Period{}
+}
+
+// String formats the period in ISO-8601.
+func (p Period) String() string {
+	if p.IsZero() {
+		return "PT0S"
+	}
+	var b strings.Builder
+	b.WriteByte('P')
+	for _, f := range []struct {
+		n int
+		d byte
+	}{{p.Years, 'Y'}, {p.Months, 'M'}, {p.Weeks, 'W'}, {p.Days, 'D'}} {
+		if f.n != 0 {
+			b.WriteString(strconv.Itoa(f.n))
+			b.WriteByte(f.d)
+		}
+	}
+	if p.Hours != 0 || p.Minutes != 0 || p.Seconds != 0 || p.Millis != 0 {
+		b.WriteByte('T')
+		if p.Hours != 0 {
+			b.WriteString(strconv.Itoa(p.Hours) + "H")
+		}
+		if p.Minutes != 0 {
+			b.WriteString(strconv.Itoa(p.Minutes) + "M")
+		}
+		if p.Seconds != 0 || p.Millis != 0 {
+			b.WriteString(strconv.Itoa(p.Seconds))
+			if p.Millis != 0 {
+				b.WriteString(fmt.Sprintf(".%03d", p.Millis))
+			}
+			b.WriteByte('S')
+		}
+	}
+	return b.String()
+}
+
+// AddTo returns t plus n times the period, n could be negative.
+// The date fields are added to the calendar of the location of t, the time fields as elapsed time,
+// so "P1D" is 23 or 25 hours across a DST change while "PT24H" is always 24 hours.
+// As in Joda, the years and months are added first and the day clamped to the end of the month,
+// so 2016-01-31 plus "P1M" is 2016-02-29, then the weeks and days.
+func (p Period) AddTo(t time.Time, n int) time.Time {
+	// The calendar goes through the local clock, which is ambiguous at the end of DST.
+	if p.Years != 0 || p.Months != 0 {
+		t = addMonths(t, (p.Years*12+p.Months)*n)
+	}
+	if p.Weeks != 0 || p.Days != 0 {
+		t = t.AddDate(0, 0, (p.Weeks*7+p.Days)*n)
+	}
+	return t.Add(p.timePart() * time.Duration(n))
+}
+
+// addMonths adds months to the date of t, the day being clamped to the last day of the resulting month.
+func addMonths(t time.Time, months int) time.Time {
+	y, m, d := t.Date()
+	hour, min, sec := t.Clock()
+	first := time.Date(y, m+time.Month(months), 1, 0, 0, 0, 0, t.Location())
+	if last := time.Date(first.Year(), first.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day(); d > last {
+		d = last
+	}
+	return time.Date(first.Year(), first.Month(), d, hour, min, sec, t.Nanosecond(), t.Location())
+}
+
+func (p Period) timePart() time.Duration {
+	return time.Duration(p.Hours)*time.Hour + time.Duration(p.Minutes)*time.Minute +
+		time.Duration(p.Seconds)*time.Second + time.Duration(p.Millis)*time.Millisecond
+}
+
+// fixed returns the duration of the period if it has no date fields.
+func (p Period) fixed() (time.Duration, bool) {
+	if p.Years != 0 || p.Months != 0 || p.Weeks != 0 || p.Days != 0 {
+		return 0, false
+	}
+	return p.timePart(), true
+}
+
+func (p Period) isSingleField() bool {
+	n := 0
+	for _, v := range []int{p.Years, p.Months, p.Weeks, p.Days, p.Hours, p.Minutes, p.Seconds, p.Millis} {
+		if v != 0 {
+			n++
+		}
+	}
+	return n == 1
+}
+
+// singleField returns the count and the unit of a single field period.
+func (p Period) singleField() (int, byte) {
+	switch {
+	case p.Years != 0:
+		return p.Years, 'Y'
+	case p.Months != 0:
+		return p.Months, 'M'
+	case p.Weeks != 0:
+		return p.Weeks, 'W'
+	case p.Days != 0:
+		return p.Days, 'D'
+	case p.Hours != 0:
+		return p.Hours, 'h'
+	case p.Minutes != 0:
+		return p.Minutes, 'm'
+	case p.Seconds != 0:
+		return p.Seconds, 's'
+	}
+	return p.Millis, 'S'
+}
@@ -0,0 +1,263 @@
+package godruid
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FormulaError reports where an arithmetic formula is invalid.
+type FormulaError struct {
+	Formula string
+	Pos     int // Byte offset in Formula.
+	Msg     string
+}
+
+func (e *FormulaError) Error() string {
+	return fmt.Sprintf("invalid formula %q at %d: %s", e.Formula, e.Pos, e.Msg)
+}
+
+// PostAggFormula parses an arithmetic formula into a tree of arithmetic post aggregations,
+// like "revenue / count * 100" or "(a + b) / hyperUnique(users)", and returns it with the names
+// of the aggregations it refers to, in order of appearance.
+//
+// The formulas are made of +, -, *, / with the usual precedence, the parentheses, the numbers, which
+// become constants, and the aggregation names, which become field accesses. The names are plain
+// identifiers or double-quoted. hyperUnique(name) refers to the cardinality of a hyperUnique aggregation
+// and fieldAccess(name) is the same as name. The chains of the same operator are put in a single
+// arithmetic, the nested post aggregations are named after their part of the formula.
+func PostAggFormula(name, formula string) (PostAggregation, []string, error) {
+	p := &formulaParser{src: formula}
+	if err := p.advance(); err != nil {
+		return PostAggregation{}, nil, err
+	}
+	pa, err := p.parseExpr()
+	if err != nil {
+		return PostAggregation{}, nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return PostAggregation{}, nil, p.errorf("unexpected %s", p.tok)
+	}
+	pa.postAgg.Name = name
+	return pa.postAgg, p.refs, nil
+}
+
+// ---------------------------------
+// Parser
+// ---------------------------------
+
+type formulaParser struct {
+	src  string
+	pos  int
+	tok  token
+	refs []string
+	seen map[string]bool
+}
+
+// formulaNode is a parsed part of the formula, with its text for the name of the post aggregation.
+type formulaNode struct {
+	postAgg PostAggregation
+	text    string
+	op      string // The operator of an arithmetic node, empty for the others.
+}
+
+func (p *formulaParser) errorf(format string, args ...interface{}) error {
+	return &FormulaError{Formula: p.src, Pos: p.tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *formulaParser) advance() error {
+	p.pos = skipSpace(p.src, p.pos)
+	start := p.pos
+	tok := func(kind tokKind, text string) error {
+		p.tok = token{kind: kind, text: text, raw: p.src[start:p.pos], pos: start}
+		return nil
+	}
+	if p.pos >= len(p.src) {
+		return tok(tokEOF, "")
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case strings.IndexByte("+-*/", c) >= 0:
+		p.pos++
+		return tok(tokOp, string(c))
+	case c == '(':
+		p.pos++
+		return tok(tokLParen, "(")
+	case c == ')':
+		p.pos++
+		return tok(tokRParen, ")")
+	case c == '"':
+		var b strings.Builder
+		for p.pos++; ; p.pos++ {
+			if p.pos >= len(p.src) {
+				p.tok.pos = start
+				return p.errorf("unterminated quote")
+			}
+			if p.src[p.pos] == '"' {
+				if p.pos+1 < len(p.src) && p.src[p.pos+1] == '"' {
+					b.WriteByte('"')
+					p.pos++
+					continue
+				}
+				p.pos++
+				break
+			}
+			b.WriteByte(p.src[p.pos])
+		}
+		return tok(tokIdent, b.String())
+	case c == '.' || c >= '0' && c <= '9':
+		for p.pos < len(p.src) {
+			c := p.src[p.pos]
+			exponent := (c == '+' || c == '-') && (p.src[p.pos-1] == 'e' || p.src[p.pos-1] == 'E')
+			if !exponent && c != '.' && c != 'e' && c != 'E' && !(c >= '0' && c <= '9') {
+				break
+			}
+			p.pos++
+		}
+		text := p.src[start:p.pos]
+		if _, err := strconv.ParseFloat(text, 64); err != nil {
+			p.tok.pos = start
+			return p.errorf("invalid number %q", text)
+		}
+		return tok(tokNumber, text)
+	}
+	if end := scanIdent(p.src, p.pos); end > p.pos {
+		p.pos = end
+		return tok(tokIdent, p.src[start:p.pos])
+	}
+	r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+	p.tok.pos = start
+	return p.errorf("unexpected %q", r)
+}
+
+func (p *formulaParser) parseExpr() (formulaNode, error) {
+	return p.parseBinary("+-", p.parseTerm)
+}
+
+func (p *formulaParser) parseTerm() (formulaNode, error) {
+	return p.parseBinary("*/", p.parseFactor)
+}
+
+// parseBinary parses a left associative chain of the operators ops between the operands.
+func (p *formulaParser) parseBinary(ops string, operand func() (formulaNode, error)) (formulaNode, error) {
+	left, err := operand()
+	if err != nil {
+		return left, err
+	}
+	for p.tok.kind == tokOp && strings.Contains(ops, p.tok.text) {
+		op := p.tok.text
+		if err := p.advance(); err != nil {
+			return left, err
+		}
+		right, err := operand()
+		if err != nil {
+			return left, err
+		}
+		left = arithmeticNode(op, left, right)
+	}
+	return left, nil
+}
+
+func arithmeticNode(op string, left, right formulaNode) formulaNode {
+	text := left.text + " " + op + " " + right.text
+	if left.op == op {
+		// a - b - c is a single arithmetic, as Druid applies the function from left to right.
+		fields := append(append([]PostAggregation(nil), left.postAgg.Fields...), right.postAgg)
+		return formulaNode{postAgg: PostAggArithmetic(text, op, fields), text: text, op: op}
+	}
+	return formulaNode{
+		postAgg: PostAggArithmetic(text, op, []PostAggregation{left.postAgg, right.postAgg}),
+		text:    text,
+		op:      op,
+	}
+}
+
+func (p *formulaParser) parseFactor() (formulaNode, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokLParen:
+		if err := p.advance(); err != nil {
+			return formulaNode{}, err
+		}
+		node, err := p.parseExpr()
+		if err != nil {
+			return node, err
+		}
+		if p.tok.kind != tokRParen {
+			return node, p.errorf("expected ')', got %s", p.tok)
+		}
+		// The parentheses make the node an operand, it can't be merged with the next operators.
+		if node.op != "" {
+			node.text, node.op = "("+node.text+")", ""
+			node.postAgg.Name = node.text
+		}
+		return node, p.advance()
+
+	case tokNumber:
+		v, _ := strconv.ParseFloat(tok.text, 64)
+		return formulaNode{postAgg: PostAggConstant(tok.text, v), text: tok.text}, p.advance()
+
+	case tokOp:
+		if tok.text != "-" {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return formulaNode{}, err
+		}
+		node, err := p.parseFactor()
+		if err != nil {
+			return node, err
+		}
+		if node.postAgg.Type == "constant" {
+			v, _ := toFloat(node.postAgg.Value)
+			return formulaNode{postAgg: PostAggConstant("-"+node.text, -v), text: "-" + node.text}, nil
+		}
+		text := "-" + node.text
+		return formulaNode{postAgg: PostAggArithmetic(text, "*", []PostAggregation{PostAggConstant("-1", -1), node.postAgg}), text: text}, nil
+
+	case tokIdent:
+		if err := p.advance(); err != nil {
+			return formulaNode{}, err
+		}
+		if p.tok.kind != tokLParen || strings.HasPrefix(tok.raw, `"`) {
+			p.addRef(tok.text)
+			return formulaNode{postAgg: PostAggFieldAccessor(tok.text), text: formatIdent(tok.text)}, nil
+		}
+		fn := tok.text
+		if fn != "hyperUnique" && fn != "hyperUniqueCardinality" && fn != "fieldAccess" {
+			return formulaNode{}, &FormulaError{Formula: p.src, Pos: tok.pos, Msg: fmt.Sprintf("unknown function %q", fn)}
+		}
+		if err := p.advance(); err != nil {
+			return formulaNode{}, err
+		}
+		arg := p.tok
+		if arg.kind != tokIdent {
+			return formulaNode{}, p.errorf("expected aggregation name, got %s", arg)
+		}
+		if err := p.advance(); err != nil {
+			return formulaNode{}, err
+		}
+		if p.tok.kind != tokRParen {
+			return formulaNode{}, p.errorf("expected ')', got %s", p.tok)
+		}
+		p.addRef(arg.text)
+		node := formulaNode{postAgg: PostAggFieldAccessor(arg.text), text: formatIdent(arg.text)}
+		if fn != "fieldAccess" {
+			node = formulaNode{postAgg: PostAggFieldHyperUnique(arg.text), text: "hyperUnique(" + formatIdent(arg.text) + ")"}
+		}
+		return node, p.advance()
+	}
+	return formulaNode{}, p.errorf("expected operand, got %s", tok)
+}
+
+func (p *formulaParser) addRef(name string) {
+	if p.seen == nil {
+		p.seen = map[string]bool{}
+	}
+	if !p.seen[name] {
+		p.seen[name] = true
+		p.refs = append(p.refs, name)
+	}
+}
@@ -0,0 +1,82 @@
+package godruid
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPostAggFormula(t *testing.T) {
+	Convey("TestPostAggFormula", t, func() {
+		pa, refs, err := PostAggFormula("pct", "revenue / count * 100")
+		So(err, ShouldBeNil)
+		So(refs, ShouldResemble, []string{"revenue", "count"})
+		So(pa, ShouldResemble, PostAggArithmetic("pct", "*", []PostAggregation{
+			PostAggArithmetic("revenue / count", "/", []PostAggregation{PostAggFieldAccessor("revenue"), PostAggFieldAccessor("count")}),
+			PostAggConstant("100", 100.0),
+		}))
+
+		pa, refs, err = PostAggFormula("x", "(a + b) / hyperUnique(users)")
+		So(err, ShouldBeNil)
+		So(refs, ShouldResemble, []string{"a", "b", "users"})
+		So(pa, ShouldResemble, PostAggArithmetic("x", "/", []PostAggregation{
+			PostAggArithmetic("(a + b)", "+", []PostAggregation{PostAggFieldAccessor("a"), PostAggFieldAccessor("b")}),
+			PostAggFieldHyperUnique("users"),
+		}))
+
+		Convey("precedence and chains", func() {
+			pa, refs, err := PostAggFormula("x", `a - b - "my metric" + a * -2.5e1`)
+			So(err, ShouldBeNil)
+			So(refs, ShouldResemble, []string{"a", "b", "my metric"})
+			So(pa.Fn, ShouldEqual, "+")
+			So(pa.Fields[0], ShouldResemble, PostAggArithmetic(`a - b - "my metric"`, "-", []PostAggregation{
+				PostAggFieldAccessor("a"), PostAggFieldAccessor("b"), PostAggFieldAccessor("my metric")}))
+			So(pa.Fields[1].Fields[1], ShouldResemble, PostAggConstant("-2.5e1", -25.0))
+
+			v, err := pa.eval(map[string]interface{}{"a": 10.0, "b": 1.0, "my metric": 2.0})
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, 10-1-2+10*-25.0)
+
+			pa, _, err = PostAggFormula("x", "a - (b - c)")
+			So(err, ShouldBeNil)
+			v, err = pa.eval(map[string]interface{}{"a": 10.0, "b": 4.0, "c": 1.0})
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, 7.0)
+
+			pa, _, err = PostAggFormula("x", "-(a) * fieldAccess(b)")
+			So(err, ShouldBeNil)
+			v, err = pa.eval(map[string]interface{}{"a": 2.0, "b": 3.0})
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, -6.0)
+			So(pa.Validate(), ShouldBeNil)
+		})
+
+		Convey("non-ASCII names", func() {
+			pa, refs, err := PostAggFormula("x", "café / count")
+			So(err, ShouldBeNil)
+			So(refs, ShouldResemble, []string{"café", "count"})
+			So(pa, ShouldResemble, PostAggArithmetic("x", "/", []PostAggregation{PostAggFieldAccessor("café"), PostAggFieldAccessor("count")}))
+
+			_, _, err = PostAggFormula("x", "a + €")
+			So(err, ShouldHaveSameTypeAs, &FormulaError{})
+			So(err.Error(), ShouldContainSubstring, `unexpected '€'`)
+		})
+
+		Convey("errors", func() {
+			for formula, pos := range map[string]int{
+				"":             0,
+				"a +":          3,
+				"(a + b":       6,
+				"a b":          2,
+				"sqrt(a)":      0,
+				"hyperUnique(": 12,
+				"a % b":        2,
+				"1e":           0,
+			} {
+				_, _, err := PostAggFormula("x", formula)
+				So(err, ShouldHaveSameTypeAs, &FormulaError{})
+				So(err.(*FormulaError).Pos, ShouldEqual, pos)
+			}
+		})
+	})
+}
@@ -2,6 +2,8 @@ package godruid
 
 import (
 	"encoding/json"
+	"fmt"
+	"math"
 )
 
 type PostAggregation struct {
@@ -16,6 +18,23 @@ type PostAggregation struct {
 	Function      string            `json:"function,omitempty"`
 	ThetaFunction ThetaFunc         `json:"func,omitempty"`
 	Ordering      string            `json:"ordering,omitempty"`
+	// The sketch post aggregations.
+	Round             bool      `json:"round,omitempty"`
+	LgK               int       `json:"lgK,omitempty"`
+	TgtHllType        HLLType   `json:"tgtHllType,omitempty"`
+	Fraction          *float64  `json:"fraction,omitempty"`
+	Fractions         []float64 `json:"fractions,omitempty"`
+	SplitPoints       []float64 `json:"splitPoints,omitempty"`
+	NumBins           int       `json:"numBins,omitempty"`
+	ErrorBoundsStdDev int       `json:"errorBoundsStdDev,omitempty"`
+	// The approximate histogram post aggregations.
+	Probability   *float64  `json:"probability,omitempty"`
+	Probabilities []float64 `json:"probabilities,omitempty"`
+	NumBuckets    int       `json:"numBuckets,omitempty"`
+	BucketSize    float64   `json:"bucketSize,omitempty"`
+	Offset        float64   `json:"offset,omitempty"`
+	Breaks        []float64 `json:"breaks,omitempty"`
+	Expression    string    `json:"expression,omitempty"`
 }
 
 type ThetaFunc string
@@ -38,6 +57,9 @@ type Ordering string
 
 func (s Ordering) apply(c *PostAggregation) { c.Ordering = string(s) }
 
+// NumericFirst orders the numbers first, then the nulls and NaNs.
+const NumericFirst Ordering = "numericFirst"
+
 type Name string
 
 func (s Name) apply(c *PostAggregation) { c.Name = string(s) }
@@ -76,6 +98,18 @@ func PostAggFieldAccessor(fieldName string, options ...PostAggOption) PostAggreg
 	return pa
 }
 
+// PostAggFinalizingFieldAccessor accesses the finalized value of an aggregation, like the estimate of a sketch.
+func PostAggFinalizingFieldAccessor(fieldName string, options ...PostAggOption) PostAggregation {
+	pa := PostAggregation{
+		Type:      "finalizingFieldAccess",
+		FieldName: fieldName,
+	}
+	for _, opt := range options {
+		opt.apply(&pa)
+	}
+	return pa
+}
+
 func PostAggConstant(name string, value interface{}) PostAggregation {
 	return PostAggregation{
 		Type:  "constant",
@@ -93,6 +127,51 @@ func PostAggJavaScript(name, function string, fieldNames []string) PostAggregati
 	}
 }
 
+// PostAggExpression computes a Druid expression over the aggregations, like "revenue / count".
+func PostAggExpression(name, expression string, options ...PostAggOption) PostAggregation {
+	pa := PostAggregation{
+		Type:       "expression",
+		Name:       name,
+		Expression: expression,
+	}
+	for _, opt := range options {
+		opt.apply(&pa)
+	}
+	return pa
+}
+
+func PostAggDoubleGreatest(name string, fields ...PostAggregation) PostAggregation {
+	return PostAggregation{
+		Type:   "doubleGreatest",
+		Name:   name,
+		Fields: fields,
+	}
+}
+
+func PostAggDoubleLeast(name string, fields ...PostAggregation) PostAggregation {
+	return PostAggregation{
+		Type:   "doubleLeast",
+		Name:   name,
+		Fields: fields,
+	}
+}
+
+func PostAggLongGreatest(name string, fields ...PostAggregation) PostAggregation {
+	return PostAggregation{
+		Type:   "longGreatest",
+		Name:   name,
+		Fields: fields,
+	}
+}
+
+func PostAggLongLeast(name string, fields ...PostAggregation) PostAggregation {
+	return PostAggregation{
+		Type:   "longLeast",
+		Name:   name,
+		Fields: fields,
+	}
+}
+
 func PostAggFieldHyperUnique(fieldName string, options ...PostAggOption) PostAggregation {
 	pa := PostAggregation{
 		Type:      "hyperUniqueCardinality",
@@ -121,6 +200,138 @@ func PostAggThetaOp(name string, function ThetaFunc, fields []PostAggregation) P
 	}
 }
 
+// PostAggThetaEstimateWithErrorBounds estimates the theta sketch with its bounds at numStdDev standard deviations.
+func PostAggThetaEstimateWithErrorBounds(name string, field PostAggregation, numStdDev int) PostAggregation {
+	return PostAggregation{
+		Type:              "thetaSketchEstimate",
+		Name:              name,
+		Field:             &field,
+		ErrorBoundsStdDev: numStdDev,
+	}
+}
+
+func PostAggHLLSketchEstimate(name string, field PostAggregation, round bool) PostAggregation {
+	return PostAggregation{
+		Type:  "HLLSketchEstimate",
+		Name:  name,
+		Field: &field,
+		Round: round,
+	}
+}
+
+// PostAggHLLSketchUnion unions the HLL sketches, lgK and tgtHllType could be left zero for the defaults.
+func PostAggHLLSketchUnion(name string, fields []PostAggregation, lgK int, tgtHllType HLLType) PostAggregation {
+	return PostAggregation{
+		Type:       "HLLSketchUnion",
+		Name:       name,
+		Fields:     fields,
+		LgK:        lgK,
+		TgtHllType: tgtHllType,
+	}
+}
+
+func PostAggHLLSketchToString(name string, field PostAggregation) PostAggregation {
+	return PostAggregation{
+		Type:  "HLLSketchToString",
+		Name:  name,
+		Field: &field,
+	}
+}
+
+func PostAggQuantilesDoublesSketchToQuantile(name string, field PostAggregation, fraction float64) PostAggregation {
+	return PostAggregation{
+		Type:     "quantilesDoublesSketchToQuantile",
+		Name:     name,
+		Field:    &field,
+		Fraction: &fraction,
+	}
+}
+
+func PostAggQuantilesDoublesSketchToQuantiles(name string, field PostAggregation, fractions ...float64) PostAggregation {
+	return PostAggregation{
+		Type:      "quantilesDoublesSketchToQuantiles",
+		Name:      name,
+		Field:     &field,
+		Fractions: fractions,
+	}
+}
+
+// PostAggQuantilesDoublesSketchToHistogram returns the histogram over the split points,
+// or over numBins equal bins between the min and the max when there are no split points.
+func PostAggQuantilesDoublesSketchToHistogram(name string, field PostAggregation, splitPoints []float64, numBins int) PostAggregation {
+	return PostAggregation{
+		Type:        "quantilesDoublesSketchToHistogram",
+		Name:        name,
+		Field:       &field,
+		SplitPoints: splitPoints,
+		NumBins:     numBins,
+	}
+}
+
+func PostAggQuantilesDoublesSketchToCDF(name string, field PostAggregation, splitPoints ...float64) PostAggregation {
+	return PostAggregation{
+		Type:        "quantilesDoublesSketchToCDF",
+		Name:        name,
+		Field:       &field,
+		SplitPoints: splitPoints,
+	}
+}
+
+func PostAggQuantilesDoublesSketchToRank(name string, field PostAggregation, value float64) PostAggregation {
+	return PostAggregation{
+		Type:  "quantilesDoublesSketchToRank",
+		Name:  name,
+		Field: &field,
+		Value: value,
+	}
+}
+
+func PostAggQuantile(name, fieldName string, probability float64) PostAggregation {
+	return PostAggregation{
+		Type:        "quantile",
+		Name:        name,
+		FieldName:   fieldName,
+		Probability: &probability,
+	}
+}
+
+func PostAggQuantiles(name, fieldName string, probabilities ...float64) PostAggregation {
+	return PostAggregation{
+		Type:          "quantiles",
+		Name:          name,
+		FieldName:     fieldName,
+		Probabilities: probabilities,
+	}
+}
+
+func PostAggEqualBuckets(name, fieldName string, numBuckets int) PostAggregation {
+	return PostAggregation{
+		Type:       "equalBuckets",
+		Name:       name,
+		FieldName:  fieldName,
+		NumBuckets: numBuckets,
+	}
+}
+
+func PostAggBuckets(name, fieldName string, bucketSize, offset float64) PostAggregation {
+	return PostAggregation{
+		Type:       "buckets",
+		Name:       name,
+		FieldName:  fieldName,
+		BucketSize: bucketSize,
+		Offset:     offset,
+	}
+}
+
+func PostAggCustomBuckets(name, fieldName string, breaks ...float64) PostAggregation {
+	return PostAggregation{
+		Type:      "customBuckets",
+		Name:      name,
+		FieldName: fieldName,
+		Breaks:    breaks,
+	}
+}
+
 // ---------------------------------
 // Helpers
 // ---------------------------------
@@ -134,26 +345,248 @@ type AggRefer struct {
 // Return the aggregations or post aggregations which this post aggregation used.
 // It could be helpful while automatically filling the aggregations or post aggregations base on this.
 func (pa PostAggregation) GetReferAggs(parentName ...string) (refers []AggRefer) {
+	parent := pa.Name
+	if len(parentName) != 0 {
+		parent = parentName[0]
+	}
 	switch pa.Type {
-	case "arithmetic":
-		if len(parentName) != 0 {
-			refers = append(refers, AggRefer{parentName[0], pa.Name})
-		} else {
-			refers = append(refers, AggRefer{pa.Name, ""})
-		}
-		for _, spa := range pa.Fields {
-			refers = append(refers, spa.GetReferAggs(pa.Name)...)
-		}
-	case "fieldAccess":
-		refers = append(refers, AggRefer{parentName[0], pa.FieldName})
+	case "fieldAccess", "finalizingFieldAccess", "hyperUniqueCardinality",
+		"quantile", "quantiles", "equalBuckets", "buckets", "customBuckets":
+		refers = append(refers, AggRefer{parent, pa.FieldName})
 	case "constant":
 		// no need refers.
 	case "javascript":
 		for _, f := range pa.FieldNames {
 			refers = append(refers, AggRefer{pa.Name, f})
 		}
-	case "hyperUniqueCardinality":
-		refers = append(refers, AggRefer{parentName[0], pa.FieldName})
+	case "expression":
+		refers = append(refers, pa.selfRefer(parentName))
+		for _, f := range expressionFields(pa.Expression) {
+			refers = append(refers, AggRefer{pa.Name, f})
+		}
+	default:
+		// The post aggregations on top of other post aggregations, like arithmetic or the sketch ones.
+		refers = append(refers, pa.selfRefer(parentName))
+		for _, spa := range pa.Fields {
+			refers = append(refers, spa.GetReferAggs(pa.Name)...)
+		}
+		if f, ok := pa.fieldPostAgg(); ok {
+			refers = append(refers, f.GetReferAggs(pa.Name)...)
+		}
+	}
+	return
+}
+
+func (pa PostAggregation) selfRefer(parentName []string) AggRefer {
+	if len(parentName) != 0 {
+		return AggRefer{parentName[0], pa.Name}
+	}
+	return AggRefer{pa.Name, ""}
+}
+
+// Validate checks the functions, the orderings and the number of fields of the post aggregation tree.
+func (pa PostAggregation) Validate() error {
+	switch pa.Type {
+	case "arithmetic":
+		switch pa.Fn {
+		case "+", "-", "*", "/", "quotient":
+		default:
+			return fmt.Errorf("arithmetic %q has unknown function %q", pa.Name, pa.Fn)
+		}
+		if len(pa.Fields) < 2 {
+			return fmt.Errorf("arithmetic %q needs at least 2 fields, has %d", pa.Name, len(pa.Fields))
+		}
+	case "doubleGreatest", "doubleLeast", "longGreatest", "longLeast":
+		if len(pa.Fields) == 0 {
+			return fmt.Errorf("%s %q has no fields", pa.Type, pa.Name)
+		}
+	case "expression":
+		if pa.Expression == "" {
+			return fmt.Errorf("expression %q is empty", pa.Name)
+		}
+	}
+	if pa.Ordering != "" && pa.Ordering != string(NumericFirst) {
+		return fmt.Errorf("post aggregation %q has unknown ordering %q", pa.Name, pa.Ordering)
+	}
+	for _, f := range pa.Fields {
+		if err := f.Validate(); err != nil {
+			return err
+		}
+	}
+	if f, ok := pa.fieldPostAgg(); ok {
+		return f.Validate()
+	}
+	return nil
+}
+
+// eval computes the post aggregation locally from the aggregated values of row.
+// Only the arithmetic, greatest, least, field accesses and constant post aggregations could be evaluated.
+func (pa PostAggregation) eval(row map[string]interface{}) (float64, error) {
+	switch pa.Type {
+	case "fieldAccess", "finalizingFieldAccess", "hyperUniqueCardinality":
+		v, ok := toFloat(row[pa.FieldName])
+		if !ok {
+			return 0, fmt.Errorf("field %q is not a number", pa.FieldName)
+		}
+		return v, nil
+	case "constant":
+		v, ok := toFloat(pa.Value)
+		if !ok {
+			return 0, fmt.Errorf("constant %q is not a number", pa.Name)
+		}
+		return v, nil
+	case "arithmetic":
+		if len(pa.Fields) == 0 {
+			return 0, fmt.Errorf("arithmetic %q has no fields", pa.Name)
+		}
+		res, err := pa.Fields[0].eval(row)
+		if err != nil {
+			return 0, err
+		}
+		for _, f := range pa.Fields[1:] {
+			v, err := f.eval(row)
+			if err != nil {
+				return 0, err
+			}
+			switch pa.Fn {
+			case "+":
+				res += v
+			case "-":
+				res -= v
+			case "*":
+				res *= v
+			case "/":
+				// Druid returns 0 when dividing by 0.
+				if v == 0 {
+					res = 0
+				} else {
+					res /= v
+				}
+			case "quotient":
+				res /= v
+			default:
+				return 0, fmt.Errorf("unknown arithmetic function %q", pa.Fn)
+			}
+		}
+		return res, nil
+	case "doubleGreatest", "doubleLeast", "longGreatest", "longLeast":
+		if len(pa.Fields) == 0 {
+			return 0, fmt.Errorf("%s %q has no fields", pa.Type, pa.Name)
+		}
+		greatest := pa.Type == "doubleGreatest" || pa.Type == "longGreatest"
+		var res float64
+		for i, f := range pa.Fields {
+			v, err := f.eval(row)
+			if err != nil {
+				return 0, err
+			}
+			if i == 0 || greatest && v > res || !greatest && v < res {
+				res = v
+			}
+		}
+		if pa.Type == "longGreatest" || pa.Type == "longLeast" {
+			res = math.Trunc(res)
+		}
+		return res, nil
+	}
+	return 0, fmt.Errorf("post aggregation %q of type %q can't be evaluated locally", pa.Name, pa.Type)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// fieldRefs returns the field access and hyperUniqueCardinality references of the post aggregation tree.
+func (pa PostAggregation) fieldRefs() (refs []PostAggregation) {
+	switch pa.Type {
+	case "fieldAccess", "finalizingFieldAccess", "hyperUniqueCardinality":
+		return []PostAggregation{pa}
+	}
+	for _, f := range pa.Fields {
+		refs = append(refs, f.fieldRefs()...)
+	}
+	if f, ok := pa.fieldPostAgg(); ok {
+		refs = append(refs, f.fieldRefs()...)
+	}
+	return
+}
+
+// fieldPostAgg returns the post aggregation of Field, set by the constructors like PostAggThetaEstimate.
+func (pa PostAggregation) fieldPostAgg() (*PostAggregation, bool) {
+	switch f := pa.Field.(type) {
+	case *PostAggregation:
+		return f, f != nil
+	case PostAggregation:
+		return &f, true
+	}
+	return nil, false
+}
+
+// expressionFields returns the identifiers of a Druid expression which aren't function names.
+func expressionFields(expr string) (fields []string) {
+	seen := map[string]bool{}
+	add := func(f string) {
+		if !seen[f] {
+			seen[f] = true
+			fields = append(fields, f)
+		}
+	}
+	isIdent := func(c byte, first bool) bool {
+		return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || !first && c >= '0' && c <= '9'
+	}
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == '\'' || c == '"':
+			// A single-quoted string or a double-quoted identifier.
+			j := i + 1
+			for j < len(expr) && expr[j] != c {
+				if expr[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j > len(expr) {
+				j = len(expr)
+			}
+			if c == '"' {
+				add(expr[i+1 : j])
+			}
+			i = j + 1
+		case c >= '0' && c <= '9' || c == '.':
+			// A number, with its exponent.
+			for i < len(expr) && (isIdent(expr[i], false) || expr[i] == '.') {
+				i++
+			}
+		case isIdent(c, true):
+			j := i
+			for j < len(expr) && isIdent(expr[j], false) {
+				j++
+			}
+			k := j
+			for k < len(expr) && expr[k] == ' ' {
+				k++
+			}
+			if k == len(expr) || expr[k] != '(' {
+				add(expr[i:j])
+			}
+			i = j
+		default:
+			i++
+		}
 	}
 	return
 }
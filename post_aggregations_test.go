@@ -0,0 +1,90 @@
+package godruid
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPostAggregations(t *testing.T) {
+	Convey("TestPostAggregations", t, func() {
+		marshal := func(pa PostAggregation) string {
+			b, err := json.Marshal(pa)
+			So(err, ShouldBeNil)
+			return string(b)
+		}
+
+		Convey("expression, greatest and least", func() {
+			So(marshal(PostAggExpression("ratio", "revenue / count", NumericFirst)), ShouldEqual,
+				`{"type":"expression","name":"ratio","ordering":"numericFirst","expression":"revenue / count"}`)
+			So(marshal(PostAggFinalizingFieldAccessor("users", Name("u"))), ShouldEqual,
+				`{"type":"finalizingFieldAccess","name":"u","fieldName":"users"}`)
+
+			row := map[string]interface{}{"a": 3.0, "b": 7.5}
+			fields := []PostAggregation{PostAggFieldAccessor("a"), PostAggFieldAccessor("b")}
+			for pa, expected := range map[*PostAggregation]float64{
+				&PostAggregation{Type: "doubleGreatest", Fields: fields}: 7.5,
+				&PostAggregation{Type: "doubleLeast", Fields: fields}:    3,
+				&PostAggregation{Type: "longGreatest", Fields: fields}:   7,
+			} {
+				v, err := pa.eval(row)
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, expected)
+			}
+			So(PostAggLongLeast("l", fields...).Type, ShouldEqual, "longLeast")
+		})
+
+		Convey("validate", func() {
+			fields := []PostAggregation{PostAggFieldAccessor("a"), PostAggConstant("c", 2)}
+			So(PostAggArithmetic("q", "quotient", fields).Validate(), ShouldBeNil)
+			So(PostAggArithmetic("q", "%", fields).Validate(), ShouldNotBeNil)
+			So(PostAggArithmetic("q", "+", fields[:1]).Validate(), ShouldNotBeNil)
+			So(PostAggArithmetic("q", "+", fields, Ordering("alpha")).Validate(), ShouldNotBeNil)
+			So(PostAggThetaEstimate("e", PostAggArithmetic("q", "^", fields)).Validate(), ShouldNotBeNil)
+			So(PostAggDoubleGreatest("g").Validate(), ShouldNotBeNil)
+			So(PostAggExpression("e", "").Validate(), ShouldNotBeNil)
+		})
+
+		Convey("GetReferAggs", func() {
+			So(PostAggFieldAccessor("revenue").GetReferAggs(), ShouldResemble, []AggRefer{{"", "revenue"}})
+			So(PostAggFieldHyperUnique("users", Name("u")).GetReferAggs(), ShouldResemble, []AggRefer{{"u", "users"}})
+
+			pa := PostAggArithmetic("ratio", "/", []PostAggregation{
+				PostAggFieldAccessor("revenue"),
+				PostAggDoubleGreatest("max", PostAggFinalizingFieldAccessor("count"), PostAggConstant("one", 1)),
+			})
+			So(pa.GetReferAggs(), ShouldResemble, []AggRefer{{"ratio", ""}, {"ratio", "revenue"}, {"ratio", "max"}, {"max", "count"}})
+
+			So(PostAggExpression("e", `sum_x / "my count" + pow(y, 2) * 1.5e3 + concat('a', b)`).GetReferAggs(), ShouldResemble,
+				[]AggRefer{{"e", ""}, {"e", "sum_x"}, {"e", "my count"}, {"e", "y"}, {"e", "b"}})
+			So(PostAggHLLSketchEstimate("n", PostAggFieldAccessor("users"), false).GetReferAggs(), ShouldResemble,
+				[]AggRefer{{"n", ""}, {"n", "users"}})
+			So(PostAggQuantile("p50", "latency", 0.5).GetReferAggs(), ShouldResemble, []AggRefer{{"p50", "latency"}})
+		})
+
+		Convey("sketches", func() {
+			sketch := PostAggFieldAccessor("latency")
+			So(marshal(PostAggQuantilesDoublesSketchToQuantile("p0", sketch, 0)), ShouldEqual,
+				`{"type":"quantilesDoublesSketchToQuantile","name":"p0","field":{"type":"fieldAccess","fieldName":"latency"},"fraction":0}`)
+			So(marshal(PostAggQuantilesDoublesSketchToQuantiles("ps", sketch, 0.5, 0.99)), ShouldEqual,
+				`{"type":"quantilesDoublesSketchToQuantiles","name":"ps","field":{"type":"fieldAccess","fieldName":"latency"},"fractions":[0.5,0.99]}`)
+			So(marshal(PostAggQuantilesDoublesSketchToHistogram("h", sketch, nil, 10)), ShouldEqual,
+				`{"type":"quantilesDoublesSketchToHistogram","name":"h","field":{"type":"fieldAccess","fieldName":"latency"},"numBins":10}`)
+			So(marshal(PostAggQuantilesDoublesSketchToCDF("cdf", sketch, 10, 100)), ShouldEqual,
+				`{"type":"quantilesDoublesSketchToCDF","name":"cdf","field":{"type":"fieldAccess","fieldName":"latency"},"splitPoints":[10,100]}`)
+			So(marshal(PostAggQuantilesDoublesSketchToRank("r", sketch, 0)), ShouldEqual,
+				`{"type":"quantilesDoublesSketchToRank","name":"r","value":0,"field":{"type":"fieldAccess","fieldName":"latency"}}`)
+
+			users := PostAggFieldAccessor("users")
+			So(marshal(PostAggHLLSketchEstimate("n", users, true)), ShouldEqual,
+				`{"type":"HLLSketchEstimate","name":"n","field":{"type":"fieldAccess","fieldName":"users"},"round":true}`)
+			So(marshal(PostAggHLLSketchUnion("u", []PostAggregation{users, PostAggFieldAccessor("visitors")}, 0, HLL4)), ShouldEqual,
+				`{"type":"HLLSketchUnion","name":"u","fields":[{"type":"fieldAccess","fieldName":"users"},{"type":"fieldAccess","fieldName":"visitors"}],"tgtHllType":"HLL_4"}`)
+			So(marshal(PostAggHLLSketchToString("s", users)), ShouldEqual,
+				`{"type":"HLLSketchToString","name":"s","field":{"type":"fieldAccess","fieldName":"users"}}`)
+			So(marshal(PostAggThetaEstimateWithErrorBounds("e", users, 2)), ShouldEqual,
+				`{"type":"thetaSketchEstimate","name":"e","field":{"type":"fieldAccess","fieldName":"users"},"errorBoundsStdDev":2}`)
+		})
+	})
+}
@@ -2,6 +2,8 @@ package godruid
 
 import (
 	"encoding/json"
+	"fmt"
+	"time"
 )
 
 // Check http://druid.io/docs/0.9.0/querying/querying.html for detail description.
@@ -12,6 +14,40 @@ type Query interface {
 	onResponse(content []byte) error
 }
 
+// ---------------------------------
+// DataSourceMetadata Query
+// ---------------------------------
+
+type QueryDataSourceMetadata struct {
+	QueryType  string                 `json:"queryType"`
+	DataSource string                 `json:"dataSource"`
+	Context    map[string]interface{} `json:"context,omitempty"`
+
+	QueryResult []DataSourceMetadataItem `json:"-"`
+}
+
+type DataSourceMetadataItem struct {
+	Timestamp string             `json:"timestamp"`
+	Result    DataSourceMetadata `json:"result"`
+}
+
+// DataSourceMetadata holds the timestamp of the latest event ingested in the datasource,
+// which is ahead of the end of its segments when the datasource is fed in real time.
+type DataSourceMetadata struct {
+	MaxIngestedEventTime time.Time `json:"maxIngestedEventTime"`
+}
+
+func (q *QueryDataSourceMetadata) setup() { q.QueryType = "dataSourceMetadata" }
+func (q *QueryDataSourceMetadata) onResponse(content []byte) error {
+	res := new([]DataSourceMetadataItem)
+	err := json.Unmarshal(content, res)
+	if err != nil {
+		return err
+	}
+	q.QueryResult = *res
+	return nil
+}
+
 // ---------------------------------
 // GroupBy Query
 // ---------------------------------
@@ -26,7 +62,7 @@ type QueryGroupBy struct {
 	Filter           *Filter                `json:"filter,omitempty"`
 	Aggregations     []Aggregation          `json:"aggregations"`
 	PostAggregations []PostAggregation      `json:"postAggregations,omitempty"`
-	Intervals        []string               `json:"intervals"`
+	Intervals        IntervalList           `json:"intervals"`
 	Context          map[string]interface{} `json:"context,omitempty"`
 
 	QueryResult []GroupbyItem `json:"-"`
@@ -38,6 +74,26 @@ type GroupbyItem struct {
 	Event     map[string]interface{} `json:"event"`
 }
 
+// UnmarshalJSON decodes a groupBy row. The unfinalized first and last aggregators return
+// {"lhs": time, "rhs": value} pairs, which are replaced by their value as if finalized.
+func (item *GroupbyItem) UnmarshalJSON(data []byte) error {
+	type plain GroupbyItem
+	if err := json.Unmarshal(data, (*plain)(item)); err != nil {
+		return err
+	}
+	for k, v := range item.Event {
+		pair, ok := v.(map[string]interface{})
+		if !ok || len(pair) != 2 {
+			continue
+		}
+		rhs, hasRhs := pair["rhs"]
+		if _, isTime := pair["lhs"].(float64); isTime && hasRhs {
+			item.Event[k] = rhs
+		}
+	}
+	return nil
+}
+
 func (q *QueryGroupBy) setup() { q.QueryType = "groupBy" }
 func (q *QueryGroupBy) onResponse(content []byte) error {
 	res := new([]GroupbyItem)
@@ -59,7 +115,7 @@ type QuerySearch struct {
 	Granularity      Granularity            `json:"granularity"`
 	Filter           *Filter                `json:"filter,omitempty"`
 	Limit            int                    `json:"filter,omitempty"`
-	Intervals        []string               `json:"intervals"`
+	Intervals        IntervalList           `json:"intervals"`
 	SearchDimensions []string               `json:"searchDimensions,omitempty"`
 	Query            *SearchQuery           `json:"query"`
 	Sort             *SearchSort            `json:"sort"`
@@ -96,37 +152,149 @@ func (q *QuerySearch) onResponse(content []byte) error {
 type QuerySegmentMetadata struct {
 	QueryType              string                 `json:"queryType"`
 	DataSource             string                 `json:"dataSource"`
-	Intervals              []string               `json:"intervals"`
+	Intervals              IntervalList           `json:"intervals"`
 	ToInclude              *ToInclude             `json:"toInclude,omitempty"`
-	Merge                  interface{}            `json:"merge,omitempty"`
+	Merge                  bool                   `json:"merge,omitempty"`
 	Context                map[string]interface{} `json:"context,omitempty"`
-	AnalysisTypes          []string               `json:"analysisTypes,omitempty"`
+	AnalysisTypes          []AnalysisType         `json:"analysisTypes,omitempty"`
 	LenientAggregatorMerge bool                   `json:"lenientAggregatorMerge,omitempty"`
 
 	QueryResult []SegmentMetaData `json:"-"`
 }
 
+// AnalysisType selects a part of the segmentMetadata results, Druid runs cardinality, interval
+// and minmax when no analysis type is given.
+type AnalysisType string
+
+const (
+	AnalysisCardinality      AnalysisType = "cardinality"
+	AnalysisSize             AnalysisType = "size"
+	AnalysisInterval         AnalysisType = "interval"
+	AnalysisAggregators      AnalysisType = "aggregators"
+	AnalysisQueryGranularity AnalysisType = "queryGranularity"
+	AnalysisTimestampSpec    AnalysisType = "timestampSpec"
+	AnalysisMinMax           AnalysisType = "minmax"
+	AnalysisRollup           AnalysisType = "rollup"
+)
+
+// SegmentMetaData is the analysis of a segment, or of all of them when merged.
+// The fields of the analysis types which weren't run are left to their zero value.
 type SegmentMetaData struct {
-	Id          string                    `json:"id"`
-	Intervals   []string                  `json:"intervals"`
-	Columns     map[string]ColumnItem     `json:"columns"`
-	Aggregators map[string]AggregatorItem `json:"aggregators"`
-	Size        int                       `json:"size"`
-	NumRows     int                       `json:"numRows"`
+	Id               string                    `json:"id"`
+	Intervals        IntervalList              `json:"intervals"`
+	Columns          map[string]ColumnItem     `json:"columns"`
+	Aggregators      map[string]AggregatorItem `json:"aggregators"`
+	TimestampSpec    *TimestampSpec            `json:"timestampSpec"`
+	QueryGranularity Granularity               `json:"queryGranularity"`
+	Rollup           *bool                     `json:"rollup"` // Nil when unknown or mixed between the segments.
+	Size             int64                     `json:"size"`
+	NumRows          int64                     `json:"numRows"`
+}
+
+// UnmarshalJSON decodes the query granularity into a SimpleGran or a ComplexGran.
+func (s *SegmentMetaData) UnmarshalJSON(data []byte) error {
+	type segmentMetaData SegmentMetaData
+	aux := struct {
+		*segmentMetaData
+		QueryGranularity json.RawMessage `json:"queryGranularity"`
+	}{segmentMetaData: (*segmentMetaData)(s)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	gran, err := decodeGranularity(aux.QueryGranularity)
+	if err != nil {
+		return err
+	}
+	s.QueryGranularity = gran
+	return nil
+}
+
+// TimestampSpec is how the timestamps of a segment were parsed at ingestion.
+type TimestampSpec struct {
+	Column       string      `json:"column"`
+	Format       string      `json:"format"`
+	MissingValue interface{} `json:"missingValue"`
 }
 
 type ColumnItem struct {
-	Type              string      `json:"type"`
-	Size              int         `json:"size,omitempty"`
-	HasMultipleValues bool        `json":hasMultipleValues"`
-	ErrorMessage      string      `json:"errorMessage"`
-	Cardinality       interface{} `json:"cardinality,omitempty"`
+	Type              string       `json:"type"`
+	Size              int64        `json:"size,omitempty"`
+	HasMultipleValues bool         `json:"hasMultipleValues"`
+	ErrorMessage      string       `json:"errorMessage"`
+	Cardinality       *int64       `json:"cardinality,omitempty"` // Nil for the numeric and complex columns.
+	MinValue          *ColumnValue `json:"minValue,omitempty"`
+	MaxValue          *ColumnValue `json:"maxValue,omitempty"`
+}
+
+// ColumnValue is a minValue or maxValue of the minmax analysis,
+// a string for the STRING columns and a number for the numeric ones.
+type ColumnValue struct {
+	String   string
+	Number   float64
+	IsNumber bool
+}
+
+// Value returns the number as a float64 or the string.
+func (v ColumnValue) Value() interface{} {
+	if v.IsNumber {
+		return v.Number
+	}
+	return v.String
+}
+
+func (v ColumnValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.Value())
 }
 
+func (v *ColumnValue) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch value := value.(type) {
+	case string:
+		*v = ColumnValue{String: value}
+	case float64:
+		*v = ColumnValue{Number: value, IsNumber: true}
+	default:
+		return fmt.Errorf("invalid column value %s", data)
+	}
+	return nil
+}
+
+// AggregatorItem is the aggregator a metric was rolled up with at ingestion,
+// Aggregation returns it with all its parameters.
 type AggregatorItem struct {
 	Type      string `json:"type"`
 	Name      string `json:"name"`
 	FieldName string `json:"fieldName"`
+	raw       json.RawMessage
+}
+
+func (a *AggregatorItem) UnmarshalJSON(data []byte) error {
+	type alias AggregatorItem
+	var item alias
+	if err := json.Unmarshal(data, &item); err != nil {
+		return err
+	}
+	*a = AggregatorItem(item)
+	if string(data) != "null" {
+		a.raw = append(json.RawMessage(nil), data...)
+	}
+	return nil
+}
+
+// Aggregation returns the aggregator with all the parameters decoded from the segment metadata,
+// like the sketch sizes or the histogram buckets.
+func (a AggregatorItem) Aggregation() (Aggregation, error) {
+	if a.raw == nil {
+		return Aggregation{Type: a.Type, Name: a.Name, FieldName: a.FieldName}, nil
+	}
+	var agg Aggregation
+	if err := json.Unmarshal(a.raw, &agg); err != nil {
+		return Aggregation{}, fmt.Errorf("invalid aggregator %q: %v", a.Name, err)
+	}
+	return agg, nil
 }
 
 func (q *QuerySegmentMetadata) setup() { q.QueryType = "segmentMetadata" }
@@ -147,7 +315,7 @@ func (q *QuerySegmentMetadata) onResponse(content []byte) error {
 type QuerySelect struct {
 	QueryType   string                 `json:"queryType"`
 	DataSource  string                 `json:"dataSource"`
-	Intervals   []string               `json:"intervals"`
+	Intervals   IntervalList           `json:"intervals"`
 	Descending  bool                   `json:"descending,omitempty"`
 	Filter      *Filter                `json:"filter,omitempty"`
 	Dimensions  []string               `json:"dimensions,omitempty"`
@@ -193,6 +361,7 @@ func (q *QuerySelect) onResponse(content []byte) error {
 type QueryTimeBoundary struct {
 	QueryType  string                 `json:"queryType"`
 	DataSource string                 `json:"dataSource"`
+	Intervals  IntervalList           `json:"intervals,omitempty"`
 	Bound      string                 `json:"bound,omitempty"`
 	Context    map[string]interface{} `json:"context,omitempty"`
 
@@ -206,7 +375,7 @@ type TimeBoundaryItem struct {
 
 type TimeBoundary struct {
 	MinTime string `json:"minTime"`
-	MaxTime string `json:"minTime"`
+	MaxTime string `json:"maxTime"`
 }
 
 func (q *QueryTimeBoundary) setup() { q.QueryType = "timeBoundary" }
@@ -228,7 +397,7 @@ type QueryTimeseries struct {
 	QueryType        string                 `json:"queryType"`
 	DataSource       string                 `json:"dataSource"`
 	Descending       bool                   `json:"descending,omitempty"`
-	Intervals        []string               `json:"intervals"`
+	Intervals        IntervalList           `json:"intervals"`
 	Granularity      Granularity            `json:"granularity"`
 	Filter           *Filter                `json:"filter,omitempty"`
 	Aggregations     []Aggregation          `json:"aggregations"`
@@ -268,7 +437,7 @@ type QueryTopN struct {
 	Filter           *Filter                `json:"filter,omitempty"`
 	Aggregations     []Aggregation          `json:"aggregations"`
 	PostAggregations []PostAggregation      `json:"postAggregations,omitempty"`
-	Intervals        []string               `json:"intervals"`
+	Intervals        IntervalList           `json:"intervals"`
 	Context          map[string]interface{} `json:"context,omitempty"`
 
 	QueryResult []TopNItem `json:"-"`
@@ -289,3 +458,57 @@ func (q *QueryTopN) onResponse(content []byte) error {
 	q.QueryResult = *res
 	return nil
 }
+
+// ---------------------------------
+// Helpers
+// ---------------------------------
+
+// queryIntervals returns the intervals of the query, false if this kind of query has no intervals.
+func queryIntervals(query Query) ([]string, bool) {
+	switch q := query.(type) {
+	case *QueryGroupBy:
+		return q.Intervals, true
+	case *QuerySearch:
+		return q.Intervals, true
+	case *QuerySegmentMetadata:
+		return q.Intervals, true
+	case *QuerySelect:
+		return q.Intervals, true
+	case *QueryTimeseries:
+		return q.Intervals, true
+	case *QueryTopN:
+		return q.Intervals, true
+	}
+	return nil, false
+}
+
+// withIntervals returns a copy of the query running over intervals, without any result.
+func withIntervals(query Query, intervals []string) (Query, bool) {
+	switch q := query.(type) {
+	case *QueryGroupBy:
+		cp := *q
+		cp.Intervals, cp.QueryResult = intervals, nil
+		return &cp, true
+	case *QuerySearch:
+		cp := *q
+		cp.Intervals, cp.QueryResult = intervals, nil
+		return &cp, true
+	case *QuerySegmentMetadata:
+		cp := *q
+		cp.Intervals, cp.QueryResult = intervals, nil
+		return &cp, true
+	case *QuerySelect:
+		cp := *q
+		cp.Intervals, cp.QueryResult = intervals, nil
+		return &cp, true
+	case *QueryTimeseries:
+		cp := *q
+		cp.Intervals, cp.QueryResult = intervals, nil
+		return &cp, true
+	case *QueryTopN:
+		cp := *q
+		cp.Intervals, cp.QueryResult = intervals, nil
+		return &cp, true
+	}
+	return nil, false
+}
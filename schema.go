@@ -0,0 +1,253 @@
+package godruid
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// AggregatorCatalog maps the metrics of a datasource to the aggregations combining their rolled up values,
+// e.g. a "count" metric is summed with a longSum and a "users" hyperUnique metric is merged with a hyperUnique.
+type AggregatorCatalog map[string]Aggregation
+
+// AggregatorCatalog runs a segmentMetadata query analyzing the aggregators of the datasource
+// over the intervals, or the default history of the broker when there is none, and returns its catalog.
+// The metrics whose aggregators differ between the segments are left out.
+func (c *Client) AggregatorCatalog(ctx context.Context, dataSource string, intervals ...string) (AggregatorCatalog, error) {
+	query := &QuerySegmentMetadata{
+		DataSource:             dataSource,
+		Intervals:              intervals,
+		Merge:                  true,
+		AnalysisTypes:          []AnalysisType{AnalysisAggregators},
+		LenientAggregatorMerge: true,
+	}
+	if err := c.QueryContext(ctx, query); err != nil {
+		return nil, err
+	}
+	catalog := AggregatorCatalog{}
+	for _, segment := range query.QueryResult {
+		for name, item := range segment.Aggregators {
+			if item.Type == "" {
+				continue
+			}
+			agg, err := item.Aggregation()
+			if err != nil {
+				return nil, err
+			}
+			catalog[name] = combiningAggregation(name, agg)
+		}
+	}
+	return catalog, nil
+}
+
+// Agg returns the aggregation of the metric named name in the results.
+func (c AggregatorCatalog) Agg(metric, name string) (Aggregation, error) {
+	agg, ok := c[metric]
+	if !ok {
+		return Aggregation{}, fmt.Errorf("unknown metric %q", metric)
+	}
+	agg.Name = name
+	return agg, nil
+}
+
+// combiningAggregation returns the aggregation reading the column of a metric rolled up by the aggregator,
+// like Druid's getCombiningFactory. The parameters of the aggregator, like the sketch sizes or the
+// histogram buckets, are kept so that the combined values are read the way they were stored.
+func combiningAggregation(metric string, agg Aggregation) Aggregation {
+	switch agg.Type {
+	case "filtered":
+		if agg.Aggregator != nil {
+			return combiningAggregation(metric, *agg.Aggregator)
+		}
+	case "count":
+		agg = Aggregation{Type: "longSum"}
+	case "cardinality":
+		agg = Aggregation{Type: "hyperUnique"}
+	case "HLLSketchBuild":
+		agg.Type = "HLLSketchMerge"
+	case "approxHistogram":
+		agg.Type = "approxHistogramFold"
+	case "thetaSketch":
+		// The column holds sketches, whatever the input of the ingestion was.
+		agg.IsInputThetaSketch = nil
+	}
+	agg.Name = metric
+	agg.FieldName = metric
+	agg.FieldNames = nil
+	agg.ByRow = nil
+	return agg
+}
+
+// ---------------------------------
+// Freshness
+// ---------------------------------
+
+// Freshness runs a dataSourceMetadata query and returns how far the latest ingested event
+// of the datasource is behind now. It is negative when the events are stamped in the future.
+func (c *Client) Freshness(ctx context.Context, dataSource string) (time.Duration, error) {
+	query := &QueryDataSourceMetadata{DataSource: dataSource}
+	if err := c.QueryContext(ctx, query); err != nil {
+		return 0, err
+	}
+	if len(query.QueryResult) == 0 {
+		return 0, fmt.Errorf("no metadata for datasource %q", dataSource)
+	}
+	return now().Sub(query.QueryResult[0].Result.MaxIngestedEventTime), nil
+}
+
+// ---------------------------------
+// DataSource schema
+// ---------------------------------
+
+// DataSourceSchema describes the columns and the extent of a datasource over an interval.
+type DataSourceSchema struct {
+	Name       string
+	MinTime    time.Time // The zero time when the interval has no data.
+	MaxTime    time.Time
+	NumRows    int64 // The rows stored in the segments, after roll up.
+	Size       int64 // The estimated size of the segments, in bytes.
+	Segments   int
+	Columns    map[string]ColumnSchema
+	Dimensions []string // Sorted names of the dimension columns.
+	Metrics    []string // Sorted names of the metric columns.
+}
+
+// ColumnSchema is a column merged from all the segments having it.
+// When the column type changed over time, Type is the one of the most recent segment
+// and Types lists every type seen, from the oldest segment to the most recent.
+type ColumnSchema struct {
+	Name              string
+	Type              string
+	Types             []string
+	HasMultipleValues bool
+	Cardinality       int64 // The highest cardinality of the segments, 0 when unknown.
+	Size              int64
+	Segments          int      // The segments having the column.
+	Errors            []string // The errors Druid reported when analyzing the column.
+}
+
+// Drifted reports whether the column doesn't have the same type in all the segments.
+func (c ColumnSchema) Drifted() bool {
+	return len(c.Types) > 1
+}
+
+// DescribeDataSource runs a segmentMetadata query, without merging the segments, and a timeBoundary query
+// over the interval, or the default history of the broker for segmentMetadata and the whole datasource
+// for timeBoundary when it is empty, and merges their results into the schema of the datasource.
+// The metrics are the columns having an aggregator and the complex columns, the other columns but __time
+// are the dimensions.
+func (c *Client) DescribeDataSource(ctx context.Context, name, interval string) (*DataSourceSchema, error) {
+	var intervals []string
+	if interval != "" {
+		intervals = []string{interval}
+	}
+	metadata := &QuerySegmentMetadata{
+		DataSource:    name,
+		Intervals:     intervals,
+		AnalysisTypes: []AnalysisType{AnalysisCardinality, AnalysisSize, AnalysisInterval, AnalysisAggregators},
+	}
+	boundary := &QueryTimeBoundary{DataSource: name, Intervals: intervals}
+	if _, err := c.QueryBatch(ctx, []Query{metadata, boundary}, FailFast(true)); err != nil {
+		return nil, err
+	}
+
+	schema := describeSegments(name, metadata.QueryResult)
+	for _, item := range boundary.QueryResult {
+		var err error
+		if item.Result.MinTime != "" {
+			if schema.MinTime, err = parseIntervalTime(item.Result.MinTime); err != nil {
+				return nil, fmt.Errorf("invalid minTime %q: %v", item.Result.MinTime, err)
+			}
+		}
+		if item.Result.MaxTime != "" {
+			if schema.MaxTime, err = parseIntervalTime(item.Result.MaxTime); err != nil {
+				return nil, fmt.Errorf("invalid maxTime %q: %v", item.Result.MaxTime, err)
+			}
+		}
+	}
+	return schema, nil
+}
+
+// describeSegments merges the analyses of the segments into a schema.
+func describeSegments(name string, segments []SegmentMetaData) *DataSourceSchema {
+	// Oldest segments first, so that the latest type of a column wins.
+	segments = append([]SegmentMetaData(nil), segments...)
+	sort.SliceStable(segments, func(i, j int) bool {
+		return segmentStart(segments[i]).Before(segmentStart(segments[j]))
+	})
+
+	schema := &DataSourceSchema{Name: name, Segments: len(segments), Columns: map[string]ColumnSchema{}}
+	metrics := map[string]bool{}
+	for _, segment := range segments {
+		schema.NumRows += segment.NumRows
+		schema.Size += segment.Size
+		for metric, item := range segment.Aggregators {
+			if item.Type != "" {
+				metrics[metric] = true
+			}
+		}
+		for column, item := range segment.Columns {
+			col := schema.Columns[column]
+			col.Name = column
+			col.Segments++
+			col.Size += item.Size
+			col.HasMultipleValues = col.HasMultipleValues || item.HasMultipleValues
+			if item.Cardinality != nil && *item.Cardinality > col.Cardinality {
+				col.Cardinality = *item.Cardinality
+			}
+			if item.ErrorMessage != "" {
+				col.Errors = append(col.Errors, item.ErrorMessage)
+			}
+			if item.Type != "" {
+				col.Type = item.Type
+				if !containsString(col.Types, item.Type) {
+					col.Types = append(col.Types, item.Type)
+				}
+			}
+			schema.Columns[column] = col
+		}
+	}
+
+	for column, col := range schema.Columns {
+		switch {
+		case column == "__time":
+		case metrics[column] || !isPrimitiveColumnType(col.Type):
+			schema.Metrics = append(schema.Metrics, column)
+		default:
+			schema.Dimensions = append(schema.Dimensions, column)
+		}
+	}
+	sort.Strings(schema.Dimensions)
+	sort.Strings(schema.Metrics)
+	return schema
+}
+
+// segmentStart returns the start of the first interval of a segment, the zero time when it is unknown.
+func segmentStart(segment SegmentMetaData) time.Time {
+	if len(segment.Intervals) == 0 {
+		return time.Time{}
+	}
+	iv, err := ParseInterval(segment.Intervals[0])
+	if err != nil {
+		return time.Time{}
+	}
+	return iv.Start
+}
+
+func isPrimitiveColumnType(t string) bool {
+	switch t {
+	case "STRING", "LONG", "FLOAT", "DOUBLE", "":
+		return true
+	}
+	return false
+}
+
+func containsString(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,219 @@
+package godruid
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAggregatorCatalog(t *testing.T) {
+	Convey("TestAggregatorCatalog", t, func() {
+		var query map[string]interface{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(body, &query)
+			w.Write([]byte(`[{"id":"merged","intervals":null,"columns":{},"size":0,"numRows":0,"aggregators":{
+				"count":{"type":"count","name":"count"},
+				"revenue":{"type":"doubleSum","name":"revenue","fieldName":"price"},
+				"users":{"type":"hyperUnique","name":"users","fieldName":"user_id"},
+				"visitors":{"type":"HLLSketchBuild","name":"visitors","fieldName":"visitor_id","lgK":12},
+				"sketch":{"type":"thetaSketch","name":"sketch","fieldName":"user_id"},
+				"big_sketch":{"type":"thetaSketch","name":"big_sketch","fieldName":"user_id","size":65536,"isInputThetaSketch":true},
+				"latency":{"type":"fixedBucketsHistogram","name":"latency","fieldName":"latency_ms",
+					"lowerLimit":0,"upperLimit":1000,"numBuckets":50,"outlierHandlingMode":"clip"},
+				"filtered":{"type":"filtered","filter":{"type":"selector","dimension":"country","value":"fr"},
+					"aggregator":{"type":"HLLSketchBuild","name":"filtered","fieldName":"user_id","lgK":14,"tgtHllType":"HLL_8"}},
+				"conflict":null}}]`))
+		}))
+		defer server.Close()
+		client := Client{Url: server.URL}
+
+		catalog, err := client.AggregatorCatalog(context.Background(), "ds", "2016-05-01/2016-05-02")
+		So(err, ShouldBeNil)
+		So(query["analysisTypes"], ShouldResemble, []interface{}{"aggregators"})
+		So(query["merge"], ShouldEqual, true)
+		So(catalog, ShouldHaveLength, 8)
+		So(catalog["count"], ShouldResemble, AggLongSum("count", "count"))
+		So(catalog["revenue"], ShouldResemble, AggDoubleSum("revenue", "revenue"))
+		So(catalog["users"], ShouldResemble, AggHyperUnique("users", "users"))
+		So(catalog["visitors"], ShouldResemble, AggHLLSketchMerge("visitors", "visitors", LgK(12)))
+		So(catalog["sketch"], ShouldResemble, AggThetaSketch("sketch", "sketch"))
+		So(catalog["big_sketch"], ShouldResemble, AggThetaSketch("big_sketch", "big_sketch", Size(65536)))
+		So(catalog["latency"], ShouldResemble, AggFixedBucketsHistogram("latency", "latency", 0, 1000, 50, OutlierClip))
+		So(catalog["filtered"], ShouldResemble, AggHLLSketchMerge("filtered", "filtered", LgK(14), HLL8))
+
+		agg, err := catalog.Agg("users", "unique_users")
+		So(err, ShouldBeNil)
+		So(agg, ShouldResemble, AggHyperUnique("unique_users", "users"))
+		_, err = catalog.Agg("conflict", "x")
+		So(err, ShouldNotBeNil)
+
+		var item AggregatorItem
+		So(json.Unmarshal([]byte(`{"type":"quantilesDoublesSketch","name":"q","fieldName":"latency","k":256}`), &item), ShouldBeNil)
+		So(item.Type, ShouldEqual, "quantilesDoublesSketch")
+		agg, err = item.Aggregation()
+		So(err, ShouldBeNil)
+		So(agg, ShouldResemble, AggQuantilesDoublesSketch("q", "latency", SketchK(256)))
+
+		agg, err = AggregatorItem{Type: "longSum", Name: "n", FieldName: "x"}.Aggregation()
+		So(err, ShouldBeNil)
+		So(agg, ShouldResemble, AggLongSum("n", "x"))
+	})
+}
+
+func TestDescribeDataSource(t *testing.T) {
+	Convey("TestDescribeDataSource", t, func() {
+		queries := map[string]map[string]interface{}{}
+		var mu sync.Mutex
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			var query map[string]interface{}
+			json.Unmarshal(body, &query)
+			mu.Lock()
+			queries[query["queryType"].(string)] = query
+			mu.Unlock()
+			if query["queryType"] == "timeBoundary" {
+				w.Write([]byte(`[{"timestamp":"2016-05-01T00:00:00.000Z","result":{
+					"minTime":"2016-05-01T00:00:00.000Z","maxTime":"2016-05-02T23:59:00.000Z"}}]`))
+				return
+			}
+			w.Write([]byte(`[{
+				"id":"ds_2016-05-02","intervals":["2016-05-02T00:00:00.000Z/2016-05-03T00:00:00.000Z"],"size":300,"numRows":30,
+				"columns":{
+					"__time":{"type":"LONG","size":10},
+					"country":{"type":"STRING","size":100,"hasMultipleValues":false,"cardinality":5},
+					"tags":{"type":"STRING","size":50,"hasMultipleValues":true,"cardinality":3},
+					"user_id":{"type":"LONG","size":40},
+					"count":{"type":"LONG","size":20},
+					"users":{"type":"hyperUnique","size":0}},
+				"aggregators":{"count":{"type":"count","name":"count"},"users":{"type":"hyperUnique","name":"users","fieldName":"user_id"}}
+			},{
+				"id":"ds_2016-05-01","intervals":["2016-05-01T00:00:00.000Z/2016-05-02T00:00:00.000Z"],"size":200,"numRows":20,
+				"columns":{
+					"__time":{"type":"LONG","size":10},
+					"country":{"type":"STRING","size":80,"hasMultipleValues":false,"cardinality":7},
+					"tags":{"type":"STRING","size":40,"hasMultipleValues":false,"cardinality":2},
+					"user_id":{"type":"STRING","size":40,"cardinality":20},
+					"count":{"type":"LONG","size":20},
+					"broken":{"type":"STRING","errorMessage":"error:cannot_analyze"}},
+				"aggregators":{"count":{"type":"count","name":"count"}}
+			}]`))
+		}))
+		defer server.Close()
+		client := Client{Url: server.URL}
+
+		schema, err := client.DescribeDataSource(context.Background(), "ds", "2016-05-01/2016-05-03")
+		So(err, ShouldBeNil)
+		So(queries["segmentMetadata"]["merge"], ShouldBeNil)
+		So(queries["segmentMetadata"]["intervals"], ShouldResemble, []interface{}{"2016-05-01/2016-05-03"})
+		So(queries["timeBoundary"]["intervals"], ShouldResemble, []interface{}{"2016-05-01/2016-05-03"})
+
+		So(schema.Name, ShouldEqual, "ds")
+		So(schema.MinTime, ShouldResemble, time.Date(2016, 5, 1, 0, 0, 0, 0, time.UTC))
+		So(schema.MaxTime, ShouldResemble, time.Date(2016, 5, 2, 23, 59, 0, 0, time.UTC))
+		So(schema.NumRows, ShouldEqual, int64(50))
+		So(schema.Size, ShouldEqual, int64(500))
+		So(schema.Segments, ShouldEqual, 2)
+		So(schema.Dimensions, ShouldResemble, []string{"broken", "country", "tags", "user_id"})
+		So(schema.Metrics, ShouldResemble, []string{"count", "users"})
+
+		So(schema.Columns["country"], ShouldResemble, ColumnSchema{
+			Name: "country", Type: "STRING", Types: []string{"STRING"}, Cardinality: 7, Size: 180, Segments: 2,
+		})
+		So(schema.Columns["tags"].HasMultipleValues, ShouldBeTrue)
+		So(schema.Columns["users"].Segments, ShouldEqual, 1)
+		So(schema.Columns["broken"].Errors, ShouldResemble, []string{"error:cannot_analyze"})
+
+		userID := schema.Columns["user_id"]
+		So(userID.Drifted(), ShouldBeTrue)
+		So(userID.Type, ShouldEqual, "LONG")
+		So(userID.Types, ShouldResemble, []string{"STRING", "LONG"})
+		So(schema.Columns["country"].Drifted(), ShouldBeFalse)
+	})
+}
+
+func TestSegmentMetaData(t *testing.T) {
+	Convey("TestSegmentMetaData", t, func() {
+		var segments []SegmentMetaData
+		err := json.Unmarshal([]byte(`[{
+			"id":"merged","intervals":["2016-05-01T00:00:00.000Z/2016-05-02T00:00:00.000Z"],"size":1024,"numRows":42,
+			"columns":{
+				"__time":{"type":"LONG","hasMultipleValues":false,"size":336,"cardinality":null,"minValue":1462060800000,"maxValue":1462147199000,"errorMessage":null},
+				"country":{"type":"STRING","hasMultipleValues":true,"size":200,"cardinality":12,"minValue":"AU","maxValue":"US","errorMessage":null},
+				"users":{"type":"hyperUnique","hasMultipleValues":false,"size":0,"cardinality":null,"minValue":null,"maxValue":null,"errorMessage":null}},
+			"aggregators":{"users":{"type":"hyperUnique","name":"users","fieldName":"user_id"}},
+			"timestampSpec":{"column":"ts","format":"millis","missingValue":null},
+			"queryGranularity":{"type":"none"},
+			"rollup":true
+		},{"id":"other","intervals":null,"columns":{},"size":0,"numRows":0,
+			"queryGranularity":{"type":"period","period":"PT1H","timeZone":"UTC","origin":null},"rollup":null}]`), &segments)
+		So(err, ShouldBeNil)
+		So(segments, ShouldHaveLength, 2)
+
+		s := segments[0]
+		So(s.NumRows, ShouldEqual, int64(42))
+		So(s.TimestampSpec, ShouldResemble, &TimestampSpec{Column: "ts", Format: "millis"})
+		So(s.QueryGranularity, ShouldEqual, GranNone)
+		So(*s.Rollup, ShouldBeTrue)
+
+		country := s.Columns["country"]
+		So(country.HasMultipleValues, ShouldBeTrue)
+		So(*country.Cardinality, ShouldEqual, int64(12))
+		So(*country.MinValue, ShouldResemble, ColumnValue{String: "AU"})
+		So(country.MaxValue.Value(), ShouldEqual, "US")
+		So(s.Columns["__time"].Cardinality, ShouldBeNil)
+		So(s.Columns["__time"].MaxValue.Value(), ShouldEqual, float64(1462147199000))
+		So(s.Columns["users"].MinValue, ShouldBeNil)
+
+		So(segments[1].QueryGranularity, ShouldResemble, ComplexGran{Type: "period", Period: "PT1H", TimeZone: "UTC"})
+		So(segments[1].Rollup, ShouldBeNil)
+		So(segments[1].TimestampSpec, ShouldBeNil)
+
+		data, err := json.Marshal(country)
+		So(err, ShouldBeNil)
+		So(string(data), ShouldContainSubstring, `"minValue":"AU","maxValue":"US"`)
+
+		q, err := NewSegmentMetadata("ds").Intervals("2016-05-01/2016-05-02").
+			AnalysisTypes(AnalysisMinMax, AnalysisRollup).Build()
+		So(err, ShouldBeNil)
+		So(q.AnalysisTypes, ShouldResemble, []AnalysisType{"minmax", "rollup"})
+	})
+}
+
+func TestFreshness(t *testing.T) {
+	Convey("TestFreshness", t, func() {
+		now = func() time.Time { return time.Date(2016, 5, 8, 13, 0, 0, 0, time.UTC) }
+		defer func() { now = time.Now }()
+
+		var query map[string]interface{}
+		response := `[{"timestamp":"2016-05-08T12:55:30.000Z","result":{"maxIngestedEventTime":"2016-05-08T12:55:30.000Z"}}]`
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(body, &query)
+			w.Write([]byte(response))
+		}))
+		defer server.Close()
+		client := Client{Url: server.URL}
+
+		lag, err := client.Freshness(context.Background(), "ds")
+		So(err, ShouldBeNil)
+		So(lag, ShouldEqual, 4*time.Minute+30*time.Second)
+		So(query, ShouldResemble, map[string]interface{}{"queryType": "dataSourceMetadata", "dataSource": "ds"})
+
+		q, err := NewDataSourceMetadata("ds").Context("timeout", 1000).Build()
+		So(err, ShouldBeNil)
+		So(client.QueryContext(context.Background(), q), ShouldBeNil)
+		So(q.QueryResult[0].Result.MaxIngestedEventTime, ShouldResemble, time.Date(2016, 5, 8, 12, 55, 30, 0, time.UTC))
+
+		response = `[]`
+		_, err = client.Freshness(context.Background(), "ds")
+		So(err, ShouldNotBeNil)
+	})
+}
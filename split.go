@@ -0,0 +1,372 @@
+package godruid
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// QuerySplit splits the intervals of a timeseries, groupBy or topN query into chunks
+// aligned to the chunk granularity, runs the chunks concurrently and merges their results into query.
+//
+// The chunk boundaries must also be bucket boundaries of the query granularity.
+// With GranAll the per chunk results are combined, which is only possible when all the aggregations
+// are additive (counts, sums, mins and maxs) and the post aggregations could be evaluated locally.
+// The combined topN results are re-ranked, so they are as approximate as the topN of Druid itself,
+// the having of a groupBy is applied to the combined rows.
+//
+// The options are passed to QueryBatch, the first failing chunk always cancels the others.
+func (c *Client) QuerySplit(ctx context.Context, query Query, chunk Granularity, options ...BatchOption) error {
+	query.setup()
+	cg, err := granSpecOf(chunk)
+	if err != nil {
+		return err
+	}
+	if cg.all || cg.none {
+		return fmt.Errorf("can't split by granularity %v", chunk)
+	}
+
+	var (
+		gran      Granularity
+		intervals []string
+		aggs      []Aggregation
+		postAggs  []PostAggregation
+	)
+	switch q := query.(type) {
+	case *QueryTimeseries:
+		gran, intervals, aggs, postAggs = q.Granularity, q.Intervals, q.Aggregations, q.PostAggregations
+	case *QueryGroupBy:
+		if q.LimitSpec != nil {
+			return fmt.Errorf("can't split a groupBy query with limitSpec")
+		}
+		gran, intervals, aggs, postAggs = q.Granularity, q.Intervals, q.Aggregations, q.PostAggregations
+	case *QueryTopN:
+		gran, intervals, aggs, postAggs = q.Granularity, q.Intervals, q.Aggregations, q.PostAggregations
+	default:
+		return fmt.Errorf("can't split query of type %T", query)
+	}
+
+	qg, err := granSpecOf(gran)
+	if err != nil {
+		return err
+	}
+	combine := qg.all
+	if combine {
+		if err := checkMergeable(aggs, postAggs); err != nil {
+			return err
+		}
+	}
+
+	chunks, err := splitIntervals(intervals, cg, qg)
+	if err != nil {
+		return err
+	}
+	subs := make([]Query, len(chunks))
+	for i, iv := range chunks {
+		subs[i], _ = withIntervals(query, []string{iv})
+		if sub, ok := subs[i].(*QueryGroupBy); ok && combine {
+			// The having applies to the combined rows, not to the ones of the chunks.
+			sub.Having = nil
+		}
+	}
+	if _, err := c.QueryBatch(ctx, subs, append(options, FailFast(true))...); err != nil {
+		return err
+	}
+
+	switch q := query.(type) {
+	case *QueryTimeseries:
+		return mergeTimeseries(q, subs, combine)
+	case *QueryGroupBy:
+		return mergeGroupBy(q, subs, combine)
+	case *QueryTopN:
+		return mergeTopN(q, subs, combine)
+	}
+	return nil
+}
+
+// splitIntervals cuts the intervals at the bucket boundaries of the chunk granularity,
+// and makes sure every cut is also a bucket boundary of the query granularity.
+func splitIntervals(intervals []string, chunk, gran granSpec) ([]string, error) {
+	var chunks []string
+	for _, iv := range intervals {
+		interval, err := ParseInterval(iv)
+		if err != nil {
+			return nil, err
+		}
+		start, end := interval.Start, interval.End
+		for cur := start; cur.Before(end); {
+			next := chunk.next(chunk.truncate(cur))
+			if !next.Before(end) {
+				next = end
+			} else if !gran.all && !gran.none && !gran.truncate(next).Equal(next) {
+				return nil, fmt.Errorf("chunk boundary %s is not aligned to the query granularity", next.Format(IntervalTimeFormat))
+			}
+			chunks = append(chunks, IntervalOf(cur, next).String())
+			cur = next
+		}
+	}
+	return chunks, nil
+}
+
+// checkMergeable returns an error if the results of the aggregations can't be merged locally.
+func checkMergeable(aggs []Aggregation, postAggs []PostAggregation) error {
+	for _, agg := range aggs {
+		if _, ok := agg.combiner(); !ok {
+			return fmt.Errorf("aggregation %q of type %q is not additive", agg.outputName(), agg.Type)
+		}
+	}
+	row := map[string]interface{}{}
+	for _, agg := range aggs {
+		row[agg.outputName()] = 1.0
+	}
+	for _, pa := range postAggs {
+		v, err := pa.eval(row)
+		if err != nil {
+			return err
+		}
+		row[pa.Name] = v
+	}
+	return nil
+}
+
+// combineRow merges the aggregated values of src into dst.
+func combineRow(dst, src map[string]interface{}, aggs []Aggregation) {
+	for _, agg := range aggs {
+		name := agg.outputName()
+		y, ok := toFloat(src[name])
+		if !ok {
+			continue
+		}
+		if x, ok := toFloat(dst[name]); ok {
+			fn, _ := agg.combiner()
+			dst[name] = fn(x, y)
+		} else {
+			dst[name] = y
+		}
+	}
+}
+
+// evalPostAggs computes the post aggregations of the merged row again.
+func evalPostAggs(row map[string]interface{}, postAggs []PostAggregation) error {
+	for _, pa := range postAggs {
+		v, err := pa.eval(row)
+		if err != nil {
+			return err
+		}
+		row[pa.Name] = v
+	}
+	return nil
+}
+
+func copyRow(row map[string]interface{}) map[string]interface{} {
+	cp := make(map[string]interface{}, len(row))
+	for k, v := range row {
+		cp[k] = v
+	}
+	return cp
+}
+
+// dimensionKey identifies the dimension values of a row, the aggregated values are left out.
+func dimensionKey(row map[string]interface{}, aggs []Aggregation, postAggs []PostAggregation) string {
+	dims := copyRow(row)
+	for _, agg := range aggs {
+		delete(dims, agg.outputName())
+	}
+	for _, pa := range postAggs {
+		delete(dims, pa.Name)
+	}
+	key, _ := json.Marshal(dims)
+	return string(key)
+}
+
+func mergeTimeseries(q *QueryTimeseries, subs []Query, combine bool) error {
+	var rows []Timeseries
+	for i := range subs {
+		if q.Descending && !combine {
+			i = len(subs) - 1 - i
+		}
+		rows = append(rows, subs[i].(*QueryTimeseries).QueryResult...)
+	}
+	if combine && len(rows) > 1 {
+		merged := Timeseries{Timestamp: rows[0].Timestamp, Result: copyRow(rows[0].Result)}
+		for _, r := range rows[1:] {
+			combineRow(merged.Result, r.Result, q.Aggregations)
+		}
+		if err := evalPostAggs(merged.Result, q.PostAggregations); err != nil {
+			return err
+		}
+		rows = []Timeseries{merged}
+	}
+	q.QueryResult = rows
+	return nil
+}
+
+func mergeGroupBy(q *QueryGroupBy, subs []Query, combine bool) error {
+	var rows []GroupbyItem
+	for _, sub := range subs {
+		rows = append(rows, sub.(*QueryGroupBy).QueryResult...)
+	}
+	if combine && len(rows) > 1 {
+		var merged []GroupbyItem
+		index := map[string]int{}
+		for _, r := range rows {
+			key := dimensionKey(r.Event, q.Aggregations, q.PostAggregations)
+			if i, ok := index[key]; ok {
+				combineRow(merged[i].Event, r.Event, q.Aggregations)
+				continue
+			}
+			index[key] = len(merged)
+			merged = append(merged, GroupbyItem{Version: r.Version, Timestamp: rows[0].Timestamp, Event: copyRow(r.Event)})
+		}
+		for _, r := range merged {
+			if err := evalPostAggs(r.Event, q.PostAggregations); err != nil {
+				return err
+			}
+		}
+		rows = merged
+	}
+	if combine && q.Having != nil {
+		var kept []GroupbyItem
+		for _, r := range rows {
+			ok, err := q.Having.Evaluate(r.Event)
+			if err != nil {
+				return err
+			}
+			if ok {
+				kept = append(kept, r)
+			}
+		}
+		rows = kept
+	}
+	q.QueryResult = rows
+	return nil
+}
+
+func mergeTopN(q *QueryTopN, subs []Query, combine bool) error {
+	var items []TopNItem
+	for _, sub := range subs {
+		items = append(items, sub.(*QueryTopN).QueryResult...)
+	}
+	if combine && len(items) > 1 {
+		dim := dimOutputName(q.Dimension)
+		less, err := topNLess(q.Metric, dim)
+		if err != nil {
+			return err
+		}
+		var merged []map[string]interface{}
+		index := map[string]int{}
+		for _, item := range items {
+			for _, r := range item.Result {
+				key := fmt.Sprint(r[dim])
+				if i, ok := index[key]; ok {
+					combineRow(merged[i], r, q.Aggregations)
+					continue
+				}
+				index[key] = len(merged)
+				merged = append(merged, copyRow(r))
+			}
+		}
+		for _, r := range merged {
+			if err := evalPostAggs(r, q.PostAggregations); err != nil {
+				return err
+			}
+		}
+		sort.SliceStable(merged, func(i, j int) bool { return less(merged[i], merged[j]) })
+		if q.Threshold > 0 && len(merged) > q.Threshold {
+			merged = merged[:q.Threshold]
+		}
+		items = []TopNItem{{Timestamp: items[0].Timestamp, Result: merged}}
+	}
+	q.QueryResult = items
+	return nil
+}
+
+// topNLess returns the ordering of the topN results defined by metric.
+func topNLess(metric *TopNMetric, dim string) (func(a, b map[string]interface{}) bool, error) {
+	if metric == nil {
+		return nil, fmt.Errorf("topN query without metric")
+	}
+	switch metric.Type {
+	case "numeric":
+		name, _ := metric.Metric.(string)
+		return func(a, b map[string]interface{}) bool {
+			x, _ := toFloat(a[name])
+			y, _ := toFloat(b[name])
+			return x > y
+		}, nil
+	case "lexicographic":
+		return func(a, b map[string]interface{}) bool {
+			return fmt.Sprint(a[dim]) < fmt.Sprint(b[dim])
+		}, nil
+	case "alphaNumeric":
+		return func(a, b map[string]interface{}) bool {
+			return compareAlphaNumeric(fmt.Sprint(a[dim]), fmt.Sprint(b[dim])) < 0
+		}, nil
+	case "inverted":
+		inner, ok := metric.Metric.(*TopNMetric)
+		if !ok {
+			return nil, fmt.Errorf("inverted topN metric without metric spec")
+		}
+		less, err := topNLess(inner, dim)
+		if err != nil {
+			return nil, err
+		}
+		return func(a, b map[string]interface{}) bool { return less(b, a) }, nil
+	}
+	return nil, fmt.Errorf("unknown topN metric type %q", metric.Type)
+}
+
+// compareAlphaNumeric compares a and b like the alphaNumeric ordering of Druid,
+// the runs of digits are compared by their numeric values.
+func compareAlphaNumeric(a, b string) int {
+	for a != "" && b != "" {
+		da, db := isDigit(a[0]), isDigit(b[0])
+		switch {
+		case da && db:
+			na, ra := leadingDigits(a)
+			nb, rb := leadingDigits(b)
+			ta, tb := strings.TrimLeft(na, "0"), strings.TrimLeft(nb, "0")
+			if len(ta) != len(tb) {
+				return cmpInt(len(ta), len(tb))
+			}
+			if ta != tb {
+				return strings.Compare(ta, tb)
+			}
+			a, b = ra, rb
+		case da != db:
+			// Digits sort before the other characters.
+			if da {
+				return -1
+			}
+			return 1
+		default:
+			if a[0] != b[0] {
+				return cmpInt(int(a[0]), int(b[0]))
+			}
+			a, b = a[1:], b[1:]
+		}
+	}
+	return cmpInt(len(a), len(b))
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func leadingDigits(s string) (digits, rest string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
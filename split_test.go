@@ -0,0 +1,131 @@
+package godruid
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSplitIntervals(t *testing.T) {
+	Convey("TestSplitIntervals", t, func() {
+		month, _ := granSpecOf(GranPeriod("P1M"))
+		day, _ := granSpecOf(GranDay)
+		chunks, err := splitIntervals([]string{"2016-01-15/2016-03-10"}, month, day)
+		So(err, ShouldBeNil)
+		So(chunks, ShouldResemble, []string{
+			"2016-01-15T00:00:00.000Z/2016-02-01T00:00:00.000Z",
+			"2016-02-01T00:00:00.000Z/2016-03-01T00:00:00.000Z",
+			"2016-03-01T00:00:00.000Z/2016-03-10T00:00:00.000Z",
+		})
+
+		week, _ := granSpecOf(GranPeriod("P1W"))
+		_, err = splitIntervals([]string{"2016-01-01/2016-03-10"}, month, week)
+		So(err, ShouldNotBeNil)
+
+		hour, _ := granSpecOf(GranHour)
+		sevenHours, _ := granSpecOf(GranDuration(7 * 3600 * 1000))
+		chunks, err = splitIntervals([]string{"2016-01-15T12:00/2016-01-17T06:00"}, day, hour)
+		So(err, ShouldBeNil)
+		So(chunks, ShouldHaveLength, 3)
+		_, err = splitIntervals([]string{"2016-01-01/2016-01-03"}, day, sevenHours)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestQuerySplit(t *testing.T) {
+	Convey("TestQuerySplit", t, func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			var q map[string]interface{}
+			json.Unmarshal(body, &q)
+			start := q["intervals"].([]interface{})[0].(string)[:24]
+			switch q["queryType"] {
+			case "timeseries":
+				w.Write([]byte(`[{"timestamp":"` + start + `","result":{"count":2,"max":` + start[9:10] + `}}]`))
+			case "groupBy":
+				if q["having"] != nil {
+					http.Error(w, "having sent with the chunk", http.StatusBadRequest)
+					return
+				}
+				w.Write([]byte(`[{"version":"v1","timestamp":"` + start + `","event":{"os":"ios","count":3}},` +
+					`{"version":"v1","timestamp":"` + start + `","event":{"os":"android","count":1}}]`))
+			case "topN":
+				w.Write([]byte(`[{"timestamp":"` + start + `","result":[{"os":"ios","count":3},{"os":"android","count":2}]}]`))
+			}
+		}))
+		defer server.Close()
+		client := Client{Url: server.URL}
+
+		Convey("timeseries", func() {
+			query := &QueryTimeseries{
+				DataSource:       "ds",
+				Intervals:        []string{"2016-01-01/2016-01-04"},
+				Granularity:      GranAll,
+				Aggregations:     []Aggregation{AggCount("count"), AggLongMax("max", "x")},
+				PostAggregations: []PostAggregation{PostAggArithmetic("ratio", "/", []PostAggregation{PostAggFieldAccessor("max"), PostAggFieldAccessor("count")})},
+			}
+			err := client.QuerySplit(context.Background(), query, GranDay)
+			So(err, ShouldBeNil)
+			So(query.QueryResult, ShouldHaveLength, 1)
+			So(query.QueryResult[0].Timestamp, ShouldEqual, "2016-01-01T00:00:00.000Z")
+			So(query.QueryResult[0].Result["count"], ShouldEqual, 6.0)
+			So(query.QueryResult[0].Result["max"], ShouldEqual, 3.0)
+			So(query.QueryResult[0].Result["ratio"], ShouldEqual, 0.5)
+
+			query.Granularity = GranHour
+			err = client.QuerySplit(context.Background(), query, GranDay)
+			So(err, ShouldBeNil)
+			So(query.QueryResult, ShouldHaveLength, 3)
+		})
+
+		Convey("topN", func() {
+			query := &QueryTopN{
+				DataSource:   "ds",
+				Intervals:    []string{"2016-01-01/2016-01-03"},
+				Granularity:  GranAll,
+				Dimension:    "os",
+				Threshold:    1,
+				Metric:       TopNMetricNumeric("count"),
+				Aggregations: []Aggregation{AggCount("count")},
+			}
+			err := client.QuerySplit(context.Background(), query, GranDay)
+			So(err, ShouldBeNil)
+			So(query.QueryResult, ShouldHaveLength, 1)
+			So(query.QueryResult[0].Result, ShouldResemble, []map[string]interface{}{{"os": "ios", "count": 6.0}})
+		})
+
+		Convey("groupBy with having", func() {
+			query := &QueryGroupBy{
+				DataSource:   "ds",
+				Intervals:    []string{"2016-01-01/2016-01-03"},
+				Granularity:  GranAll,
+				Dimensions:   []DimSpec{"os"},
+				Aggregations: []Aggregation{AggCount("count")},
+				Having:       HavingGreaterThan("count", 5),
+			}
+			err := client.QuerySplit(context.Background(), query, GranDay)
+			So(err, ShouldBeNil)
+			So(query.QueryResult, ShouldHaveLength, 1)
+			So(query.QueryResult[0].Event, ShouldResemble, map[string]interface{}{"os": "ios", "count": 6.0})
+		})
+
+		Convey("not additive", func() {
+			query := &QueryTimeseries{
+				DataSource:   "ds",
+				Intervals:    []string{"2016-01-01/2016-01-04"},
+				Granularity:  GranAll,
+				Aggregations: []Aggregation{AggHyperUnique("users", "users")},
+			}
+			So(client.QuerySplit(context.Background(), query, GranDay), ShouldNotBeNil)
+
+			query.Aggregations = []Aggregation{AggCount("count")}
+			query.PostAggregations = []PostAggregation{PostAggJavaScript("js", "function(count) { return count }", []string{"count"})}
+			So(client.QuerySplit(context.Background(), query, GranDay), ShouldNotBeNil)
+		})
+	})
+}